@@ -0,0 +1,274 @@
+package goarubacloud
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	defaultSSHUser          = "root"
+	defaultSSHPort          = 22
+	defaultBootstrapTimeout = 10 * time.Minute
+	sshPollInterval         = 5 * time.Second
+	sshHandshakeTimeout     = 30 * time.Second
+)
+
+// Bootstrap configures a new Cloud Server over SSH, as the templates don't support user data.
+// Files are uploaded first, then the script is run with /bin/sh. Only Linux templates are
+// supported.
+type Bootstrap struct {
+	// User to log in as. Defaults to root
+	User string
+
+	// Password of the user, usually the AdministratorPassword of the create request
+	Password string
+
+	// Keys used to log in, e.g. parsed with ssh.ParsePrivateKey
+	Signers []ssh.Signer
+
+	// Checks the host key of the server. Defaults to accepting any key, as the key of a new
+	// server isn't known in advance
+	HostKeyCallback ssh.HostKeyCallback
+
+	// Port of the SSH server. Defaults to 22
+	Port int
+
+	// How long to wait for SSH to come up. Defaults to 10 minutes
+	Timeout time.Duration
+
+	Files  []BootstrapFile
+	Script string
+
+	// Receive the output of the script while it runs. Output is discarded if nil
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// Optional function used to connect to the server. Defaults to net.DialTimeout
+	Dial func(network, address string) (net.Conn, error)
+}
+
+// BootstrapFile is a file uploaded by a Bootstrap.
+type BootstrapFile struct {
+	Path    string
+	Mode    os.FileMode
+	Content []byte
+}
+
+// BootstrapResult is the outcome of a Bootstrap.
+type BootstrapResult struct {
+	Address    string
+	ExitStatus int
+}
+
+// BootstrapServer waits until the jobs of the server are done and runs the bootstrap
+// against its public IP address. Nothing is run in dry-run mode.
+func BootstrapServer(client *Client, serverId int, bootstrap *Bootstrap) (*BootstrapResult, error) {
+	if bootstrap == nil {
+		return nil, NewArgError("bootstrap", "cannot be nil")
+	}
+	if client.DryRun {
+		log.Printf("[INFO] Dry run, not bootstrapping server %d\n", serverId)
+		return &BootstrapResult{}, nil
+	}
+
+	err := WaitForServerJobsDone(client, serverId)
+	if err != nil {
+		return nil, err
+	}
+
+	serverDetails, _, err := client.CloudServers.Get(serverId)
+	if err != nil {
+		return nil, err
+	}
+
+	address, err := serverDetails.GetPublicIpAddress()
+	if err != nil {
+		return nil, err
+	}
+
+	return bootstrap.Run(address)
+}
+
+// Run waits for SSH to come up on host, uploads the files and runs the script. A script
+// exiting with a non-zero status returns the result together with an error.
+func (b *Bootstrap) Run(host string) (*BootstrapResult, error) {
+	if b.Password == "" && len(b.Signers) == 0 {
+		return nil, NewArgError("bootstrap", "either Password or Signers must be set")
+	}
+
+	port := b.Port
+	if port == 0 {
+		port = defaultSSHPort
+	}
+	result := &BootstrapResult{Address: net.JoinHostPort(host, strconv.Itoa(port))}
+
+	client, err := b.connect(result.Address)
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	for _, file := range b.Files {
+		if err := b.upload(client, file); err != nil {
+			return nil, err
+		}
+	}
+
+	if b.Script == "" {
+		return result, nil
+	}
+
+	log.Printf("[INFO] Running bootstrap script on %s\n", result.Address)
+	result.ExitStatus, err = b.run(client, "/bin/sh -s", strings.NewReader(b.Script), b.Stdout, b.Stderr)
+	if err != nil {
+		return nil, err
+	}
+	if result.ExitStatus != 0 {
+		return result, fmt.Errorf("Bootstrap script on %s exited with status %d", result.Address, result.ExitStatus)
+	}
+
+	return result, nil
+}
+
+func (b *Bootstrap) config() *ssh.ClientConfig {
+	user := b.User
+	if user == "" {
+		user = defaultSSHUser
+	}
+
+	auth := []ssh.AuthMethod{}
+	if len(b.Signers) > 0 {
+		auth = append(auth, ssh.PublicKeys(b.Signers...))
+	}
+	if b.Password != "" {
+		password := b.Password
+		auth = append(auth, ssh.Password(password),
+			ssh.KeyboardInteractive(func(user, instruction string, questions []string, echos []bool) ([]string, error) {
+				answers := make([]string, len(questions))
+				for i := range answers {
+					answers[i] = password
+				}
+				return answers, nil
+			}))
+	}
+
+	hostKeyCallback := b.HostKeyCallback
+	if hostKeyCallback == nil {
+		hostKeyCallback = ssh.InsecureIgnoreHostKey()
+	}
+
+	return &ssh.ClientConfig{
+		User:            user,
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         sshHandshakeTimeout,
+	}
+}
+
+// connect retries until the SSH server accepts the login. New servers refuse connections
+// or logins for a while after their jobs are done.
+func (b *Bootstrap) connect(address string) (*ssh.Client, error) {
+	dial := b.Dial
+	if dial == nil {
+		dial = func(network, address string) (net.Conn, error) {
+			return net.DialTimeout(network, address, sshHandshakeTimeout)
+		}
+	}
+
+	timeout := b.Timeout
+	if timeout == 0 {
+		timeout = defaultBootstrapTimeout
+	}
+	deadline := time.Now().Add(timeout)
+	config := b.config()
+
+	for {
+		conn, err := dial("tcp", address)
+		if err == nil {
+			// ClientConfig.Timeout only applies to ssh.Dial, a stalled handshake would block
+			handshakeDeadline := time.Now().Add(sshHandshakeTimeout)
+			if handshakeDeadline.After(deadline) {
+				handshakeDeadline = deadline
+			}
+			conn.SetDeadline(handshakeDeadline)
+
+			var sshConn ssh.Conn
+			var chans <-chan ssh.NewChannel
+			var reqs <-chan *ssh.Request
+			sshConn, chans, reqs, err = ssh.NewClientConn(conn, address, config)
+			if err == nil {
+				conn.SetDeadline(time.Time{})
+				return ssh.NewClient(sshConn, chans, reqs), nil
+			}
+			conn.Close()
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("SSH on %s not available after %s: %s", address, timeout, err)
+		}
+		log.Printf("[DEBUG] Waiting for SSH on %s: %s\n", address, err)
+		time.Sleep(sshPollInterval)
+	}
+}
+
+func (b *Bootstrap) upload(client *ssh.Client, file BootstrapFile) error {
+	if file.Path == "" {
+		return NewArgError("Path", "cannot be empty")
+	}
+	mode := file.Mode
+	if mode == 0 {
+		mode = 0644
+	}
+
+	command := fmt.Sprintf("mkdir -p %s && cat > %s && chmod %o %s", shellQuote(path.Dir(file.Path)),
+		shellQuote(file.Path), mode.Perm(), shellQuote(file.Path))
+
+	log.Printf("[INFO] Uploading %s\n", file.Path)
+	var stderr bytes.Buffer
+	exitStatus, err := b.run(client, command, bytes.NewReader(file.Content), nil, &stderr)
+	if err != nil {
+		return err
+	}
+	if exitStatus != 0 {
+		return fmt.Errorf("Upload of %s failed with status %d: %s", file.Path, exitStatus,
+			strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// run runs the command in a new session and returns its exit status.
+func (b *Bootstrap) run(client *ssh.Client, command string, stdin io.Reader, stdout io.Writer, stderr io.Writer) (int, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return 0, err
+	}
+	defer session.Close()
+
+	session.Stdin = stdin
+	session.Stdout = stdout
+	session.Stderr = stderr
+
+	err = session.Run(command)
+	if exitError, ok := err.(*ssh.ExitError); ok {
+		return exitError.ExitStatus(), nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return 0, nil
+}
+
+// shellQuote quotes a string for /bin/sh.
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
@@ -0,0 +1,237 @@
+package goarubacloud
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+const testSSHPassword = "Secret-123"
+
+// startStubSSHServer accepts password logins and runs exec requests with the local /bin/sh.
+func startStubSSHServer(t *testing.T) (string, int) {
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if conn.User() == defaultSSHUser && string(password) == testSSHPassword {
+				return nil, nil
+			}
+			return nil, ssh.ErrNoAuth
+		},
+	}
+	config.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveStubSSH(conn, config)
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+	return host, portNumber
+}
+
+func serveStubSSH(conn net.Conn, config *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "only sessions are supported")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		go func() {
+			defer channel.Close()
+			for req := range requests {
+				if req.Type != "exec" {
+					req.Reply(false, nil)
+					continue
+				}
+				req.Reply(true, nil)
+
+				var payload struct{ Command string }
+				ssh.Unmarshal(req.Payload, &payload)
+
+				cmd := exec.Command("/bin/sh", "-c", payload.Command)
+				cmd.Stdin = channel
+				cmd.Stdout = channel
+				cmd.Stderr = channel.Stderr()
+				status := 0
+				if err := cmd.Run(); err != nil {
+					status = 1
+					if exitError, ok := err.(*exec.ExitError); ok {
+						status = exitError.ExitCode()
+					}
+				}
+
+				exitStatus := make([]byte, 4)
+				binary.BigEndian.PutUint32(exitStatus, uint32(status))
+				channel.SendRequest("exit-status", false, exitStatus)
+				return
+			}
+		}()
+	}
+}
+
+// syncBuffer collects the streamed output of a script.
+type syncBuffer struct {
+	mu     sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buffer.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buffer.String()
+}
+
+func TestBootstrap_Run(t *testing.T) {
+	host, port := startStubSSHServer(t)
+	dir := t.TempDir()
+	target := filepath.Join(dir, "etc", "app's config.yml")
+
+	var stdout, stderr syncBuffer
+	bootstrap := &Bootstrap{
+		Password: testSSHPassword,
+		Port:     port,
+		Timeout:  10 * time.Second,
+		Files:    []BootstrapFile{{Path: target, Mode: 0600, Content: []byte("listen: 8080\n")}},
+		Script:   "cat " + shellQuote(target) + "\necho warning >&2\nexit 3\n",
+		Stdout:   &stdout,
+		Stderr:   &stderr,
+	}
+
+	result, err := bootstrap.Run(host)
+	if err == nil {
+		t.Fatal("expected an error for exit status 3")
+	}
+	if result == nil || result.ExitStatus != 3 {
+		t.Fatalf("result = %+v, expected exit status 3", result)
+	}
+
+	content, err := os.ReadFile(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "listen: 8080\n" {
+		t.Errorf("uploaded content = %q", content)
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("mode = %o, expected 600", info.Mode().Perm())
+	}
+
+	if stdout.String() != "listen: 8080\n" {
+		t.Errorf("stdout = %q", stdout.String())
+	}
+	if strings.TrimSpace(stderr.String()) != "warning" {
+		t.Errorf("stderr = %q", stderr.String())
+	}
+}
+
+func TestBootstrap_RunSuccess(t *testing.T) {
+	host, port := startStubSSHServer(t)
+
+	var stdout syncBuffer
+	bootstrap := &Bootstrap{Password: testSSHPassword, Port: port, Timeout: 10 * time.Second,
+		Script: "echo ready\n", Stdout: &stdout}
+
+	result, err := bootstrap.Run(host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ExitStatus != 0 || stdout.String() != "ready\n" {
+		t.Errorf("result = %+v, stdout = %q", result, stdout.String())
+	}
+}
+
+func TestBootstrap_RunStalledHandshake(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	stalled := make(chan struct{})
+	defer close(stalled)
+
+	// Accept connections but never start the SSH handshake
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				<-stalled
+				conn.Close()
+			}()
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+	bootstrap := &Bootstrap{Password: testSSHPassword, Port: portNumber, Timeout: time.Second}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := bootstrap.Run(host)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("expected an error for a stalled handshake")
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Run blocked on a stalled handshake")
+	}
+}
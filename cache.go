@@ -0,0 +1,326 @@
+package goarubacloud
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultCacheTTLs are the cache TTLs per action used by EnableCache when no TTLs are given.
+var DefaultCacheTTLs = map[string]time.Duration{
+	hypervisorsPath:       time.Hour,
+	isoImagesPath:         time.Hour,
+	smartPackagesPath:     time.Hour,
+	cloudSeverListPath:    10 * time.Second,
+	cloudSeverDetailsPath: 5 * time.Second,
+	purchasedIpsListPath:  30 * time.Second,
+	vLANsListPath:         30 * time.Second,
+	virtualDatacenterPath: 30 * time.Second,
+}
+
+// Resources a read action depends on. Any other action changes the resource named in it
+// and invalidates the cached reads depending on that resource.
+const (
+	cacheServers   = "server"
+	cacheIPs       = "ip"
+	cacheVLANs     = "vlan"
+	cacheTemplates = "template"
+
+	// Invalidation of all resources
+	cacheAll = "*"
+)
+
+var cacheDependencies = map[string][]string{
+	hypervisorsPath:       {cacheTemplates},
+	isoImagesPath:         {cacheTemplates},
+	smartPackagesPath:     {},
+	cloudSeverListPath:    {cacheServers},
+	cloudSeverDetailsPath: {cacheServers, cacheIPs, cacheVLANs},
+	purchasedIpsListPath:  {cacheIPs, cacheServers},
+	vLANsListPath:         {cacheVLANs, cacheServers},
+	virtualDatacenterPath: {cacheServers, cacheIPs, cacheVLANs},
+	activeJobsPath:        {cacheServers},
+}
+
+// EnableCache caches the responses of the actions with a TTL. Concurrent identical calls are
+// made only once. Cached responses are invalidated when a changing action is made against
+// the same resource. If ttls is nil DefaultCacheTTLs are used.
+func (c *Client) EnableCache(ttls map[string]time.Duration) {
+	if ttls == nil {
+		ttls = DefaultCacheTTLs
+	}
+	c.cache = &responseCache{
+		ttls:        ttls,
+		entries:     map[string]*cacheEntry{},
+		inFlight:    map[string]*cacheCall{},
+		invalidated: map[string]uint64{},
+	}
+}
+
+// DisableCache stops caching and drops all cached responses.
+func (c *Client) DisableCache() {
+	c.cache = nil
+}
+
+// InvalidateCache drops all cached responses.
+func (c *Client) InvalidateCache() {
+	if c.cache != nil {
+		c.cache.invalidateAll()
+	}
+}
+
+// forgetCached drops the cached responses of an action, so that wait helpers see fresh data.
+func (c *Client) forgetCached(action string) {
+	if c.cache != nil {
+		c.cache.drop(action)
+	}
+}
+
+type responseCache struct {
+	mu       sync.Mutex
+	ttls     map[string]time.Duration
+	entries  map[string]*cacheEntry
+	inFlight map[string]*cacheCall
+
+	// Generation of the last invalidation per resource or dropped action. Reads started
+	// before an invalidation of their dependencies are not cached.
+	generation  uint64
+	invalidated map[string]uint64
+}
+
+type cacheEntry struct {
+	action   string
+	serverId int
+	expires  time.Time
+	resp     *http.Response
+	data     []byte
+}
+
+type cacheCall struct {
+	action     string
+	generation uint64
+
+	done chan struct{}
+	resp *http.Response
+	data []byte
+	err  error
+}
+
+type sendFunc func(*http.Request) (*http.Response, []byte, error)
+
+func (rc *responseCache) fetch(req *http.Request, action string, send sendFunc) (*http.Response, []byte, error) {
+	body, err := requestBody(req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !isReadAction(action) {
+		resp, data, err := send(req)
+		rc.invalidate(action, findServerId(body))
+		return resp, data, err
+	}
+
+	ttl := rc.ttls[action]
+	if ttl == 0 {
+		return send(req)
+	}
+
+	key := cacheKey(action, body)
+
+	rc.mu.Lock()
+	if entry, ok := rc.entries[key]; ok && time.Now().Before(entry.expires) {
+		rc.mu.Unlock()
+		log.Printf("[DEBUG] Cached response for %s\n", action)
+		return copyResponse(entry.resp, entry.data), entry.data, nil
+	}
+	if call, ok := rc.inFlight[key]; ok {
+		rc.mu.Unlock()
+		<-call.done
+		if call.err != nil {
+			return nil, nil, call.err
+		}
+		return copyResponse(call.resp, call.data), call.data, nil
+	}
+	call := &cacheCall{action: action, generation: rc.generation, done: make(chan struct{})}
+	rc.inFlight[key] = call
+	rc.mu.Unlock()
+
+	call.resp, call.data, call.err = send(req)
+
+	rc.mu.Lock()
+	if rc.inFlight[key] == call {
+		delete(rc.inFlight, key)
+	}
+	if call.err == nil && succeeded(call.data) && !rc.invalidatedSince(action, call.generation) {
+		rc.entries[key] = &cacheEntry{
+			action:   action,
+			serverId: findServerId(body),
+			expires:  time.Now().Add(ttl),
+			resp:     call.resp,
+			data:     call.data,
+		}
+	}
+	rc.mu.Unlock()
+	close(call.done)
+
+	return call.resp, call.data, call.err
+}
+
+// invalidate drops the cached reads depending on the resource changed by the action. Server
+// details of other servers are kept if the action names a server.
+func (rc *responseCache) invalidate(action string, serverId int) {
+	resource := cacheServers
+	switch {
+	case strings.Contains(action, "IpAddress"):
+		resource = cacheIPs
+	case strings.Contains(action, "VLan"):
+		resource = cacheVLANs
+	case strings.Contains(action, "Template"):
+		resource = cacheTemplates
+	}
+
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.markInvalidated(resource)
+
+	for key, entry := range rc.entries {
+		if !dependsOn(entry.action, resource) {
+			continue
+		}
+		if resource == cacheServers && serverId != 0 && entry.serverId != 0 && entry.serverId != serverId {
+			continue
+		}
+		delete(rc.entries, key)
+	}
+}
+
+func (rc *responseCache) drop(action string) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.markInvalidated(action)
+
+	for key, entry := range rc.entries {
+		if entry.action == action {
+			delete(rc.entries, key)
+		}
+	}
+}
+
+func (rc *responseCache) invalidateAll() {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.markInvalidated(cacheAll)
+	rc.entries = map[string]*cacheEntry{}
+}
+
+// markInvalidated records an invalidation of a resource or action. Calls in flight for
+// depending reads are not shared with later reads anymore. Must be called with rc.mu held.
+func (rc *responseCache) markInvalidated(resource string) {
+	rc.generation++
+	rc.invalidated[resource] = rc.generation
+
+	for key, call := range rc.inFlight {
+		if resource == cacheAll || resource == call.action || dependsOn(call.action, resource) {
+			delete(rc.inFlight, key)
+		}
+	}
+}
+
+// invalidatedSince reports whether the action was invalidated after the generation. Must be
+// called with rc.mu held.
+func (rc *responseCache) invalidatedSince(action string, generation uint64) bool {
+	for resource, invalidated := range rc.invalidated {
+		if invalidated > generation && (resource == cacheAll || resource == action || dependsOn(action, resource)) {
+			return true
+		}
+	}
+	return false
+}
+
+func dependsOn(action string, resource string) bool {
+	dependencies, ok := cacheDependencies[action]
+	if !ok {
+		return true
+	}
+	for _, dependency := range dependencies {
+		if dependency == resource {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheKey identifies identical requests. The RequestId and SessionId differ between
+// identical requests and are left out.
+func cacheKey(action string, body []byte) string {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return action + "\x00" + string(body)
+	}
+	delete(fields, "RequestId")
+	delete(fields, "SessionId")
+
+	// Maps are marshalled with sorted keys
+	key, err := json.Marshal(fields)
+	if err != nil {
+		return action + "\x00" + string(body)
+	}
+	return action + "\x00" + string(key)
+}
+
+func requestBody(req *http.Request) ([]byte, error) {
+	if req.GetBody == nil {
+		return nil, nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return ioutil.ReadAll(body)
+}
+
+// findServerId returns the first ServerId found in a JSON request body or 0.
+func findServerId(body []byte) int {
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return 0
+	}
+	return findServerIdIn(value)
+}
+
+func findServerIdIn(value interface{}) int {
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return 0
+	}
+	for key, field := range object {
+		if strings.EqualFold(key, "ServerId") {
+			if id, ok := field.(float64); ok {
+				return int(id)
+			}
+		}
+	}
+	for _, field := range object {
+		if id := findServerIdIn(field); id != 0 {
+			return id
+		}
+	}
+	return 0
+}
+
+func succeeded(data []byte) bool {
+	result := struct{ Success bool }{}
+	return json.Unmarshal(data, &result) == nil && result.Success
+}
+
+func copyResponse(resp *http.Response, data []byte) *http.Response {
+	copied := *resp
+	copied.Body = ioutil.NopCloser(bytes.NewReader(data))
+	return &copied
+}
@@ -0,0 +1,177 @@
+package goarubacloud
+
+import (
+	"fmt"
+	"log"
+)
+
+// CloneOptions overrides the settings copied from the source server by CloudServers.Clone.
+type CloneOptions struct {
+	// Password of the new server. Required, passwords can not be read from the API
+	AdministratorPassword string
+
+	// New CPU and RAM quantities of PRO servers. Zero keeps the source values
+	CPUQuantity int
+	RAMQuantity int
+
+	// Tags added to or replacing the tags copied from the source note
+	Tags map[string]string
+
+	// Purchase a new public IP and attach it to a PRO server
+	PublicIP bool
+
+	// Don't join the VLANs of the source server
+	SkipVLANs bool
+}
+
+// NewCloneRequest builds a create request equivalent to an existing server, with the same
+// template, size, disk layout, note and tags.
+func NewCloneRequest(source *CloudServerDetails, newName string, options *CloneOptions) (CloudServerCreator, error) {
+	if source == nil {
+		return nil, NewArgError("source", "cannot be nil")
+	}
+	if newName == "" {
+		return nil, NewArgError("newName", "cannot be empty")
+	}
+	if options == nil || options.AdministratorPassword == "" {
+		return nil, NewArgError("AdministratorPassword", "cannot be empty")
+	}
+
+	text, tags := ParseNoteTags(source.Note)
+	for key, value := range options.Tags {
+		tags[key] = value
+	}
+	note, err := FormatNoteTags(text, tags)
+	if err != nil {
+		return nil, err
+	}
+
+	var createRequest CloudServerCreator
+	if source.EasyCloudPackageID != 0 {
+		createRequest = NewCloudServerSmartCreateRequest(CloudServerSmartSize(source.EasyCloudPackageID),
+			newName, options.AdministratorPassword, source.OSTemplate.Id)
+	} else {
+		proRequest := NewCloudServerProCreateRequest(newName, options.AdministratorPassword, source.OSTemplate.Id)
+
+		cpuQuantity := source.CPUQuantity.Quantity
+		if options.CPUQuantity != 0 {
+			cpuQuantity = options.CPUQuantity
+		}
+		if err := proRequest.SetCPUQuantity(cpuQuantity); err != nil {
+			return nil, err
+		}
+
+		ramQuantity := source.RAMQuantity.Quantity
+		if options.RAMQuantity != 0 {
+			ramQuantity = options.RAMQuantity
+		}
+		if err := proRequest.SetRAMQuantity(ramQuantity); err != nil {
+			return nil, err
+		}
+
+		for _, disk := range source.VirtualDisks {
+			if err := proRequest.AddVirtualDisk(disk.Size); err != nil {
+				return nil, err
+			}
+		}
+		createRequest = proRequest
+	}
+
+	if err := createRequest.SetNote(note); err != nil {
+		return nil, err
+	}
+
+	return createRequest, nil
+}
+
+// Clone creates a new CloudServer like an existing one. Once the new server is up it joins
+// the VLANs of the source server.
+func (s *CloudServersServiceOp) Clone(serverId int, newName string, options *CloneOptions) (*CloudServer, *Response, error) {
+	source, resp, err := s.Get(serverId)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	createRequest, err := NewCloneRequest(source, newName, options)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var ip *PurchasedIP
+	if options.PublicIP {
+		proRequest, ok := createRequest.(CloudServerProCreator)
+		if !ok {
+			return nil, nil, NewArgError("PublicIP", "smart servers come with their own public IP")
+		}
+		ip, resp, err = s.client.PurchasedIPs.Purchase()
+		if err != nil {
+			return nil, resp, err
+		}
+		if err := proRequest.AddPublicIp(ip.ResourceId); err != nil {
+			s.releasePurchasedIP(ip)
+			return nil, nil, err
+		}
+	}
+
+	server, resp, err := s.Create(createRequest)
+	if err != nil {
+		if ip != nil {
+			s.releasePurchasedIP(ip)
+		}
+		return nil, resp, err
+	}
+
+	// The VLANs can't be attached without the id of the new server
+	if options.SkipVLANs || s.client.DryRun {
+		return server, resp, nil
+	}
+
+	var vlans []PurchasedVLAN
+	for _, adapter := range source.NetworkAdapters {
+		if adapter.VLan.ResourceId != 0 {
+			vlans = append(vlans, adapter.VLan)
+		}
+	}
+	if len(vlans) == 0 {
+		return server, resp, nil
+	}
+
+	err = WaitForServerJobsDone(s.client, server.ServerId)
+	if err != nil {
+		return server, nil, err
+	}
+
+	for _, vlan := range vlans {
+		log.Printf("[INFO] Attaching server %s to VLAN %s\n", newName, vlan.Name)
+
+		details, resp, err := s.Get(server.ServerId)
+		if err != nil {
+			return server, resp, err
+		}
+		adapterId, err := details.FreePrivateNetworkAdapter()
+		if err != nil {
+			return server, nil, fmt.Errorf("Unable to attach VLAN '%s': %s", vlan.Name, err)
+		}
+		_, resp, err = s.client.VLANs.Attach(NewPurchasedVLanAttachRequest(adapterId, vlan.ResourceId))
+		if err != nil {
+			return server, resp, err
+		}
+		err = WaitForServerJobsDone(s.client, server.ServerId)
+		if err != nil {
+			return server, nil, err
+		}
+	}
+
+	return server, resp, nil
+}
+
+// releasePurchasedIP deletes an IP purchased for a clone which could not be created.
+func (s *CloudServersServiceOp) releasePurchasedIP(ip *PurchasedIP) {
+	if ip.ResourceId == DryRunResourceId {
+		return
+	}
+	log.Printf("[INFO] Releasing purchased IP %s\n", ip.Value)
+	if _, err := s.client.PurchasedIPs.Delete(ip.ResourceId); err != nil {
+		log.Printf("[WARN] Unable to release purchased IP %s: %s\n", ip.Value, err)
+	}
+}
@@ -1,10 +1,17 @@
 package goarubacloud
 
+import "fmt"
+
 const cloudServerPowerOffPath = "SetEnqueueServerPowerOff"
 const cloudServerPowerOnPath = "SetEnqueueServerStart"
 const cloudServerArchivePath = "ArchiveVirtualServer"
 const cloudServerRestorePath = "SetEnqueueServerRestore"
 const cloudServerReinitializePath = "SetEnqueueReinitializeServer"
+const cloudServerResizePath = "SetEnqueueResourceUpgrade"
+const cloudServerVirtualDiskPath = "SetEnqueueVirtualDiskManage"
+const isoImagesPath = "GetISOImages"
+const cloudServerMountISOPath = "SetEnqueueMountISO"
+const cloudServerUnmountISOPath = "SetEnqueueUmountISO"
 
 // CloudServerActionsService is an interface for interfacing with the Cloud Server actions
 // endpoints of the Arubacloud API
@@ -15,6 +22,13 @@ type CloudServerActionsService interface {
 	Archive(int) (*Response, error)
 	Restore(serverId int, CPUQuantity int, RAMQuantity int) (*Response, error)
 	Reinitialize(*ServerReinitializeRequest) (*Response, error)
+	Resize(*ServerResizeRequest) (*Response, error)
+	AttachDisk(serverId int, size int) (int, *Response, error)
+	DetachDisk(serverId int, virtualDiskType int) (*Response, error)
+	ResizeDisk(serverId int, virtualDiskType int, size int) (*Response, error)
+	ListISOImages() ([]ISOImage, *Response, error)
+	MountISO(serverId int, isoImageId int) (*Response, error)
+	UnmountISO(serverId int) (*Response, error)
 }
 
 // CloudServerActionsServiceOp handles communication with the Cloud Server action related
@@ -38,6 +52,40 @@ type ServerReinitializeRequest struct {
 	ConfigureIPv6         bool   `json:"ConfigureIPv6,omitempty"`
 }
 
+// ServerResizeRequest changes the CPU, RAM and disk sizes of a Cloud Server PRO.
+// Zero values keep the current size.
+type ServerResizeRequest struct {
+	ServerId     int
+	CPUQuantity  int                            `json:"CPUQuantity,omitempty"`
+	RAMQuantity  int                            `json:"RAMQuantity,omitempty"`
+	VirtualDisks []CloudServerCreateVirtualDisk `json:"VirtualDisks,omitempty"`
+}
+
+// ISOImage is an ISO image which can be mounted in the virtual DVD drive of a Cloud Server.
+type ISOImage struct {
+	Id          int
+	Name        string
+	Description string
+	OSFamily    int
+	Enabled     bool
+}
+
+type isoImagesRoot struct {
+	ISOImages []ISOImage `json:"Value"`
+}
+
+type isoMountRequest struct {
+	ServerId   int
+	ISOImageId int `json:"ISOImageId,omitempty"`
+}
+
+type virtualDiskRequest struct {
+	ServerId                 int
+	VirtualDiskOperationType string
+	VirtualDiskType          int
+	Size                     int `json:"Size,omitempty"`
+}
+
 type cloudServerActionRequest struct {
 	ActionPath            string
 	ServerIdCreateRequest *ServerIdCreate
@@ -134,6 +182,21 @@ func (s *CloudServerActionsServiceOp) Reinitialize(serverReinitializeRequest *Se
 		return resp, err
 	}
 
+	if serverReinitializeRequest.AdministratorPassword != "" {
+		templateId := serverReinitializeRequest.OSTemplateID
+		if templateId == 0 {
+			templateId = serverDetails.OSTemplate.Id
+		}
+		hypervisors, resp, err := s.client.Hypervisors.GetHypervisors()
+		if err != nil {
+			return resp, err
+		}
+		template, _ := findOsTemplateById(hypervisors, templateId)
+		if err := ValidateAdministratorPassword(serverReinitializeRequest.AdministratorPassword, template); err != nil {
+			return nil, err
+		}
+	}
+
 	if serverDetails.ServerStatus == ON {
 		resp, err = s.PowerOff(serverId)
 		if err != nil {
@@ -160,6 +223,290 @@ func (s *CloudServerActionsServiceOp) Reinitialize(serverReinitializeRequest *Se
 	return resp, err
 }
 
+// Resize a Cloud Server PRO. The new sizes are validated against the ResourceBounds of the
+// server's template. Servers on Hyper-V are powered off for the change and powered on again
+// afterwards. Resize returns once the new sizes show in the server details.
+func (s *CloudServerActionsServiceOp) Resize(resizeRequest *ServerResizeRequest) (*Response, error) {
+	if resizeRequest == nil {
+		return nil, NewArgError("resizeRequest", "cannot be nil")
+	}
+
+	serverId := resizeRequest.ServerId
+	serverDetails, resp, err := s.proServerDetails(serverId, "smart servers can not be resized")
+	if err != nil {
+		return resp, err
+	}
+
+	hypervisors, resp, err := s.client.Hypervisors.GetHypervisors()
+	if err != nil {
+		return resp, err
+	}
+
+	template, _ := findOsTemplateById(hypervisors, serverDetails.OSTemplate.Id)
+	if err := validateResize(resizeRequest, serverDetails, template); err != nil {
+		return nil, err
+	}
+
+	return s.withPowerOff(serverDetails, func() (*Response, error) {
+		data := struct {
+			Server interface{} `json:"Server"`
+		}{resizeRequest}
+
+		req, err := s.client.NewRequest(cloudServerResizePath, data)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := s.client.Do(req, nil)
+		if err != nil {
+			return resp, err
+		}
+
+		return resp, waitForServerDetails(s.client, serverId, resizeRequest.appliedTo)
+	})
+}
+
+// AttachDisk adds a virtual disk to a Cloud Server PRO in the next free VirtualDiskType slot
+// and waits for the job to finish. It returns the slot of the new disk.
+func (s *CloudServerActionsServiceOp) AttachDisk(serverId int, size int) (int, *Response, error) {
+	if err := validateVirtualDiskSize(size); err != nil {
+		return 0, nil, err
+	}
+
+	serverDetails, resp, err := s.proServerDetails(serverId, "virtual disks of smart servers can not be changed")
+	if err != nil {
+		return 0, resp, err
+	}
+
+	virtualDiskType := -1
+	for slot := 0; slot < maxVirtualDisks; slot++ {
+		if serverDetails.VirtualDisk(slot) == nil {
+			virtualDiskType = slot
+			break
+		}
+	}
+	if virtualDiskType == -1 {
+		return 0, nil, NewArgError("operation", "max disk count is 4")
+	}
+
+	resp, err = s.withPowerOff(serverDetails, func() (*Response, error) {
+		return s.manageVirtualDisk(&virtualDiskRequest{ServerId: serverId, VirtualDiskOperationType: "Create",
+			VirtualDiskType: virtualDiskType, Size: size})
+	})
+	return virtualDiskType, resp, err
+}
+
+// DetachDisk removes a virtual disk from a Cloud Server PRO and waits for the job to finish.
+// The primary disk can not be removed.
+func (s *CloudServerActionsServiceOp) DetachDisk(serverId int, virtualDiskType int) (*Response, error) {
+	if virtualDiskType == 0 {
+		return nil, NewArgError("virtualDiskType", "the primary disk can not be removed")
+	}
+
+	serverDetails, resp, err := s.proServerDetails(serverId, "virtual disks of smart servers can not be changed")
+	if err != nil {
+		return resp, err
+	}
+
+	if serverDetails.VirtualDisk(virtualDiskType) == nil {
+		return nil, NewArgError("virtualDiskType", fmt.Sprintf("server has no disk %d", virtualDiskType))
+	}
+
+	return s.withPowerOff(serverDetails, func() (*Response, error) {
+		return s.manageVirtualDisk(&virtualDiskRequest{ServerId: serverId, VirtualDiskOperationType: "Delete",
+			VirtualDiskType: virtualDiskType})
+	})
+}
+
+// ResizeDisk grows a virtual disk of a Cloud Server PRO and waits for the job to finish.
+func (s *CloudServerActionsServiceOp) ResizeDisk(serverId int, virtualDiskType int, size int) (*Response, error) {
+	resp, err := s.Resize(&ServerResizeRequest{
+		ServerId:     serverId,
+		VirtualDisks: []CloudServerCreateVirtualDisk{{VirtualDiskType: virtualDiskType, Size: size}},
+	})
+	if err != nil {
+		return resp, err
+	}
+
+	return resp, WaitForServerJobsDone(s.client, serverId)
+}
+
+// ListISOImages lists the ISO images available for the virtual DVD drive
+func (s *CloudServerActionsServiceOp) ListISOImages() ([]ISOImage, *Response, error) {
+	req, err := s.client.NewRequest(isoImagesPath, nil)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	root := new(isoImagesRoot)
+	resp, err := s.client.Do(req, root)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	return root.ISOImages, resp, err
+}
+
+// MountISO mounts an ISO image in the virtual DVD drive of a Cloud Server and waits for the
+// job to finish.
+func (s *CloudServerActionsServiceOp) MountISO(serverId int, isoImageId int) (*Response, error) {
+	if isoImageId == 0 {
+		return nil, NewArgError("isoImageId", "it must be > 0")
+	}
+
+	return s.doISOAction(cloudServerMountISOPath, &isoMountRequest{ServerId: serverId, ISOImageId: isoImageId})
+}
+
+// UnmountISO removes the ISO image from the virtual DVD drive of a Cloud Server and waits
+// for the job to finish.
+func (s *CloudServerActionsServiceOp) UnmountISO(serverId int) (*Response, error) {
+	return s.doISOAction(cloudServerUnmountISOPath, &isoMountRequest{ServerId: serverId})
+}
+
+func (s *CloudServerActionsServiceOp) doISOAction(actionPath string, mountRequest *isoMountRequest) (*Response, error) {
+	req, err := s.client.NewRequest(actionPath, mountRequest)
+
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.client.Do(req, nil)
+	if err != nil {
+		return resp, err
+	}
+
+	return resp, WaitForServerJobsDone(s.client, mountRequest.ServerId)
+}
+
+// proServerDetails returns the details of a Cloud Server PRO. Smart servers are rejected with
+// the reason.
+func (s *CloudServerActionsServiceOp) proServerDetails(serverId int, reason string) (*CloudServerDetails, *Response, error) {
+	serverDetails, resp, err := s.client.CloudServers.Get(serverId)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	if serverDetails.EasyCloudPackageID != 0 || serverDetails.HypervisorType == VMWare_Cloud_Smart {
+		return nil, nil, NewArgError("serverId", reason)
+	}
+
+	return serverDetails, resp, nil
+}
+
+func (s *CloudServerActionsServiceOp) manageVirtualDisk(diskRequest *virtualDiskRequest) (*Response, error) {
+	data := struct {
+		VirtualDisk interface{} `json:"VirtualDisk"`
+	}{diskRequest}
+
+	req, err := s.client.NewRequest(cloudServerVirtualDiskPath, data)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.client.Do(req, nil)
+	if err != nil {
+		return resp, err
+	}
+
+	return resp, WaitForServerJobsDone(s.client, diskRequest.ServerId)
+}
+
+// withPowerOff runs the operation on a server which is powered off first if the hypervisor
+// requires it. The server is powered on again and ON when withPowerOff returns, also if the
+// operation failed.
+func (s *CloudServerActionsServiceOp) withPowerOff(serverDetails *CloudServerDetails, operation func() (*Response, error)) (resp *Response, err error) {
+	serverId := serverDetails.ServerId
+	powerCycle := serverDetails.ServerStatus == ON && serverDetails.HypervisorType.requiresPowerOffForResize()
+	if powerCycle {
+		resp, err = s.PowerOff(serverId)
+		if err != nil {
+			return resp, err
+		}
+		defer func() {
+			powerOnErr := s.powerOnAndWait(serverId)
+			if err == nil {
+				err = powerOnErr
+			} else if powerOnErr != nil {
+				err = fmt.Errorf("%s, powering the server on again failed: %s", err, powerOnErr)
+			}
+		}()
+
+		err = WaitForServerStatus(s.client, serverId, OFF)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return operation()
+}
+
+func (s *CloudServerActionsServiceOp) powerOnAndWait(serverId int) error {
+	_, err := s.PowerOn(serverId)
+	if err != nil {
+		return err
+	}
+	return WaitForServerStatus(s.client, serverId, ON)
+}
+
+func validateResize(resizeRequest *ServerResizeRequest, serverDetails *CloudServerDetails, template *OSTemplate) error {
+	if resizeRequest.CPUQuantity < 0 {
+		return NewArgError("CPUQuantity", "it must be > 1")
+	}
+	if resizeRequest.RAMQuantity < 0 {
+		return NewArgError("RAMQuantity", "it must be > 1")
+	}
+
+	if template != nil {
+		if bounds := template.Bounds(RESOURCE_CPU); resizeRequest.CPUQuantity != 0 && bounds != nil &&
+			!bounds.Contains(resizeRequest.CPUQuantity) {
+			return NewArgError("CPUQuantity", fmt.Sprintf("it must be between %d and %d", bounds.Min, bounds.Max))
+		}
+		if bounds := template.Bounds(RESOURCE_RAM); resizeRequest.RAMQuantity != 0 && bounds != nil &&
+			!bounds.Contains(resizeRequest.RAMQuantity) {
+			return NewArgError("RAMQuantity", fmt.Sprintf("it must be between %d and %d", bounds.Min, bounds.Max))
+		}
+	}
+
+	for _, disk := range resizeRequest.VirtualDisks {
+		current := serverDetails.VirtualDisk(disk.VirtualDiskType)
+		if current == nil {
+			return NewArgError("VirtualDiskType", fmt.Sprintf("server has no disk %d", disk.VirtualDiskType))
+		}
+		if disk.Size < current.Size {
+			return NewArgError("size", "disks can only grow")
+		}
+		if err := validateVirtualDiskSize(disk.Size); err != nil {
+			return err
+		}
+		if template != nil {
+			bounds := template.Bounds(virtual_disk_resource_types[disk.VirtualDiskType])
+			if bounds != nil && !bounds.Contains(disk.Size) {
+				return NewArgError("size", fmt.Sprintf("it must be between %d and %d", bounds.Min, bounds.Max))
+			}
+		}
+	}
+
+	return nil
+}
+
+// appliedTo reports whether the server details show the requested sizes.
+func (r *ServerResizeRequest) appliedTo(serverDetails *CloudServerDetails) bool {
+	if r.CPUQuantity != 0 && serverDetails.CPUQuantity.Quantity != r.CPUQuantity {
+		return false
+	}
+	if r.RAMQuantity != 0 && serverDetails.RAMQuantity.Quantity != r.RAMQuantity {
+		return false
+	}
+	for _, disk := range r.VirtualDisks {
+		current := serverDetails.VirtualDisk(disk.VirtualDiskType)
+		if current == nil || current.Size != disk.Size {
+			return false
+		}
+	}
+	return true
+}
+
 func (s *CloudServerActionsServiceOp) doAction(actionRequest *cloudServerActionRequest) (*Response, error) {
 	req, err := s.client.NewRequest(actionRequest.ActionPath, actionRequest.ServerIdCreateRequest)
 
@@ -0,0 +1,233 @@
+package goarubacloud
+
+import (
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// CloudServerFilter reports whether a CloudServer matches. Filters run client side and can
+// be combined with And, Or and Not.
+type CloudServerFilter func(*CloudServer) bool
+
+// And returns a filter matching servers matched by f and all other filters.
+func (f CloudServerFilter) And(filters ...CloudServerFilter) CloudServerFilter {
+	return MatchAllServers(append([]CloudServerFilter{f}, filters...)...)
+}
+
+// Or returns a filter matching servers matched by f or any other filter.
+func (f CloudServerFilter) Or(filters ...CloudServerFilter) CloudServerFilter {
+	return MatchAnyServer(append([]CloudServerFilter{f}, filters...)...)
+}
+
+// Not returns a filter matching servers not matched by f.
+func (f CloudServerFilter) Not() CloudServerFilter {
+	return func(server *CloudServer) bool {
+		return !f(server)
+	}
+}
+
+// MatchAllServers returns a filter matching servers matched by all filters.
+func MatchAllServers(filters ...CloudServerFilter) CloudServerFilter {
+	return func(server *CloudServer) bool {
+		for _, filter := range filters {
+			if !filter(server) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// MatchAnyServer returns a filter matching servers matched by any filter.
+func MatchAnyServer(filters ...CloudServerFilter) CloudServerFilter {
+	return func(server *CloudServer) bool {
+		for _, filter := range filters {
+			if filter(server) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// ServerName matches servers with exactly the given name.
+func ServerName(name string) CloudServerFilter {
+	return func(server *CloudServer) bool {
+		return server.Name == name
+	}
+}
+
+// ServerNamePrefix matches servers whose name starts with prefix.
+func ServerNamePrefix(prefix string) CloudServerFilter {
+	return func(server *CloudServer) bool {
+		return strings.HasPrefix(server.Name, prefix)
+	}
+}
+
+// ServerNameGlob matches server names against a shell pattern like "web-*".
+func ServerNameGlob(pattern string) (CloudServerFilter, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, NewArgError("pattern", err.Error())
+	}
+
+	return func(server *CloudServer) bool {
+		matched, _ := path.Match(pattern, server.Name)
+		return matched
+	}, nil
+}
+
+// ServerNameRegexp matches server names against a regular expression.
+func ServerNameRegexp(expr string) (CloudServerFilter, error) {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, NewArgError("expr", err.Error())
+	}
+
+	return func(server *CloudServer) bool {
+		return re.MatchString(server.Name)
+	}, nil
+}
+
+// ServerStatusIn matches servers in any of the statuses.
+func ServerStatusIn(statuses ...ServerStatus) CloudServerFilter {
+	return func(server *CloudServer) bool {
+		for _, status := range statuses {
+			if server.ServerStatus == status {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// ServerHypervisorIn matches servers running on any of the hypervisor types.
+func ServerHypervisorIn(hypervisorTypes ...HypervisorType) CloudServerFilter {
+	return func(server *CloudServer) bool {
+		for _, hypervisorType := range hypervisorTypes {
+			if server.HypervisorType == hypervisorType {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// ServerOSTemplate matches servers created from the template.
+func ServerOSTemplate(osTemplateId int) CloudServerFilter {
+	return func(server *CloudServer) bool {
+		return server.OSTemplateId == osTemplateId
+	}
+}
+
+// ServerBusy matches servers by their Busy flag.
+func ServerBusy(busy bool) CloudServerFilter {
+	return func(server *CloudServer) bool {
+		return server.Busy == busy
+	}
+}
+
+// ServerCPUBetween matches servers with min to max CPUs. Zero bounds are open.
+func ServerCPUBetween(min int, max int) CloudServerFilter {
+	return func(server *CloudServer) bool {
+		return inRange(server.CPUQuantity, min, max)
+	}
+}
+
+// ServerRAMBetween matches servers with min to max GB of RAM. Zero bounds are open.
+func ServerRAMBetween(min int, max int) CloudServerFilter {
+	return func(server *CloudServer) bool {
+		return inRange(server.RAMQuantity, min, max)
+	}
+}
+
+func inRange(value int, min int, max int) bool {
+	return (min == 0 || value >= min) && (max == 0 || value <= max)
+}
+
+type CloudServerSortKey int
+
+const (
+	SORT_BY_NAME CloudServerSortKey = 1 + iota
+	SORT_BY_ID
+	SORT_BY_STATUS
+	SORT_BY_CPU
+	SORT_BY_RAM
+)
+
+// ListOptions filters and sorts the result of CloudServers.List.
+type ListOptions struct {
+	// Servers must match all filters
+	Filters []CloudServerFilter
+
+	// Sort order. The API order is kept if not set
+	SortBy     CloudServerSortKey
+	Descending bool
+}
+
+// Apply filters and sorts the servers. It can be used on any list of servers, e.g. to
+// select the targets of bulk operations.
+func (o *ListOptions) Apply(servers []CloudServer) []CloudServer {
+	if o == nil {
+		return servers
+	}
+
+	filter := MatchAllServers(o.Filters...)
+	matched := []CloudServer{}
+	for i := range servers {
+		if filter(&servers[i]) {
+			matched = append(matched, servers[i])
+		}
+	}
+
+	if o.SortBy != 0 {
+		less := o.less()
+		sort.SliceStable(matched, func(i, j int) bool {
+			if o.Descending {
+				return less(&matched[j], &matched[i])
+			}
+			return less(&matched[i], &matched[j])
+		})
+	}
+
+	return matched
+}
+
+func (o *ListOptions) less() func(a, b *CloudServer) bool {
+	switch o.SortBy {
+	case SORT_BY_ID:
+		return func(a, b *CloudServer) bool { return a.ServerId < b.ServerId }
+	case SORT_BY_STATUS:
+		return func(a, b *CloudServer) bool { return a.ServerStatus < b.ServerStatus }
+	case SORT_BY_CPU:
+		return func(a, b *CloudServer) bool { return a.CPUQuantity < b.CPUQuantity }
+	case SORT_BY_RAM:
+		return func(a, b *CloudServer) bool { return a.RAMQuantity < b.RAMQuantity }
+	}
+	return func(a, b *CloudServer) bool { return a.Name < b.Name }
+}
+
+// ListWithOptions lists the CloudServers matching the options
+func (s *CloudServersServiceOp) ListWithOptions(options *ListOptions) ([]CloudServer, *Response, error) {
+	servers, resp, err := s.List()
+	if err != nil {
+		return nil, resp, err
+	}
+
+	return options.Apply(servers), resp, nil
+}
+
+// ListIds lists the ids of the CloudServers matching the options
+func (s *CloudServersServiceOp) ListIds(options *ListOptions) ([]int, *Response, error) {
+	servers, resp, err := s.ListWithOptions(options)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	ids := make([]int, len(servers))
+	for i, server := range servers {
+		ids[i] = server.ServerId
+	}
+	return ids, resp, nil
+}
@@ -3,6 +3,7 @@ package goarubacloud
 import (
 	"fmt"
 	"log"
+	"net"
 	"strings"
 	"time"
 )
@@ -11,14 +12,20 @@ const cloudSeverListPath = "GetServers"
 const cloudSeverDetailsPath = "GetServerDetails"
 const cloudSeverCreatePath = "SetEnqueueServerCreation"
 const cloudSeverDeletePath = "SetEnqueueServerDeletion"
+const cloudSeverUpdatePath = "SetUpdateServerData"
 
 // CloudServersService is an interface for interfacing with the Cloud Server
 // endpoints of the Arubacloud API
 type CloudServersService interface {
 	List() ([]CloudServer, *Response, error)
+	ListWithOptions(*ListOptions) ([]CloudServer, *Response, error)
+	ListIds(*ListOptions) ([]int, *Response, error)
 	Get(int) (*CloudServerDetails, *Response, error)
 	Create(CloudServerCreator) (*CloudServer, *Response, error)
 	Delete(int) (*Response, error)
+	Preflight(CloudServerCreator) error
+	Update(serverId int, name string, note string) (*Response, error)
+	Clone(serverId int, newName string, options *CloneOptions) (*CloudServer, *Response, error)
 }
 
 // CloudServersServiceOp handles communication with the Cloud Server related methods of the
@@ -72,7 +79,7 @@ type CloudServerDetails struct {
 	Snapshots                 []interface{}
 	ToolsAvailable            bool
 	UserId                    int
-	VirtualDVDs               []interface{}
+	VirtualDVDs               []VirtualDVD
 	VirtualDisks              []VirtualDisk
 	VncPort                   int
 }
@@ -129,6 +136,17 @@ type RAMQuantity struct {
 	Quantity     int
 }
 
+// VirtualDVD is the virtual DVD drive of a Cloud Server with the ISO image mounted in it.
+type VirtualDVD struct {
+	CompanyId    int
+	ProductId    int
+	ResourceId   int
+	ResourceType int
+	UserId       int
+	ISOImageId   int
+	Name         string
+}
+
 type VirtualDisk struct {
 	CompanyId    int
 	ProductId    int
@@ -167,19 +185,33 @@ const (
 	EXTRALARGE
 )
 
-func GetServerSmartSize(size string) (CloudServerSmartSize, error) {
-	switch strings.ToUpper(size) {
-	case "SMALL":
-		return SMALL, nil
-	case "MEDIUM":
-		return MEDIUM, nil
-	case "LARGE":
-		return LARGE, nil
-	case "EXTRALARGE":
-		return EXTRALARGE, nil
-	default:
-		return 0, fmt.Errorf("size '%s' is wrong. Supported values are: 'SMALL', 'MEDIUM','LARGE','EXTRALARGE'", size)
+var smart_sizes = [...]string{
+	"SMALL",
+	"MEDIUM",
+	"LARGE",
+	"EXTRALARGE",
+}
+
+// String returns the name of the CloudServerSmartSize.
+func (m CloudServerSmartSize) String() string {
+	if m < SMALL || m > EXTRALARGE {
+		return fmt.Sprintf("CloudServerSmartSize(%d)", int(m))
+	}
+	return smart_sizes[m-1]
+}
+
+// ParseCloudServerSmartSize returns the CloudServerSmartSize with the name, ignoring case.
+func ParseCloudServerSmartSize(size string) (CloudServerSmartSize, error) {
+	for i, name := range smart_sizes {
+		if strings.EqualFold(size, name) {
+			return CloudServerSmartSize(i + 1), nil
+		}
 	}
+	return 0, fmt.Errorf("size '%s' is wrong. Supported values are: 'SMALL', 'MEDIUM','LARGE','EXTRALARGE'", size)
+}
+
+func GetServerSmartSize(size string) (CloudServerSmartSize, error) {
+	return ParseCloudServerSmartSize(size)
 }
 
 type NetworkAdapter struct {
@@ -219,14 +251,17 @@ type CloudServerCreator interface {
 	GetServerName() string
 	GetRequest() interface{}
 	SetNote(string) error
+	EnableIPv6() error
 }
 
 type CloudServerProCreator interface {
 	AddVirtualDisk(int) error
 	AddPublicIp(int) error
+	AddVLAN(vlanResourceId int, ipAddress string, gateway string, mask string) error
 	SetCPUQuantity(int) error
 	SetRAMQuantity(int) error
 	SetNote(string) error
+	EnableIPv6() error
 	GetServerName() string
 	GetRequest() interface{}
 }
@@ -255,6 +290,35 @@ func NewCloudServerSmartCreateRequest(smart_server_size CloudServerSmartSize, na
 	}
 }
 
+// NewCloudServerProCreateRequestForTemplate returns a create request for a CloudServer PRO
+// which is validated against the ResourceBounds of the template. CPU and RAM start at the
+// template defaults. The administrator password is validated against the password policy
+// of the template.
+func NewCloudServerProCreateRequestForTemplate(name string, admin_password string, template *OSTemplate) (CloudServerProCreator, error) {
+	if template == nil {
+		return nil, NewArgError("template", "cannot be nil")
+	}
+	if err := ValidateAdministratorPassword(admin_password, template); err != nil {
+		return nil, err
+	}
+
+	createRequest := NewCloudServerProCreateRequest(name, admin_password, template.Id).(*cloudServerCreateRequestPro)
+	createRequest.template = template
+
+	if bounds := template.Bounds(RESOURCE_CPU); bounds != nil && bounds.Default != 0 {
+		if err := createRequest.SetCPUQuantity(bounds.Default); err != nil {
+			return nil, err
+		}
+	}
+	if bounds := template.Bounds(RESOURCE_RAM); bounds != nil && bounds.Default != 0 {
+		if err := createRequest.SetRAMQuantity(bounds.Default); err != nil {
+			return nil, err
+		}
+	}
+
+	return createRequest, nil
+}
+
 // cloudServerCreateRequestPro represents a request to create a CloudServer PRO.
 type cloudServerCreateRequestPro struct {
 	Name                         string
@@ -265,18 +329,51 @@ type cloudServerCreateRequestPro struct {
 	RAMQuantity                  int
 	VirtualDisks                 []CloudServerCreateVirtualDisk
 	NetworkAdaptersConfiguration []NetworkAdapterCreateConfiguration
+	ConfigureIPv6                bool `json:"ConfigureIPv6,omitempty"`
+
+	// Template the request is validated against, if known
+	template *OSTemplate
 }
 
-func (r *cloudServerCreateRequestPro) AddVirtualDisk(size int) error {
-	if len(r.VirtualDisks) == 4 {
-		return NewArgError("operation", "max disk count is 4")
+// checkBounds validates a value against the ResourceBounds of the request's template.
+func (r *cloudServerCreateRequestPro) checkBounds(resourceType ResourceType, arg string, value int) error {
+	if r.template == nil {
+		return nil
+	}
+	bounds := r.template.Bounds(resourceType)
+	if bounds == nil || bounds.Contains(value) {
+		return nil
 	}
-	if size < 10 || size > 500 {
+	return NewArgError(arg, fmt.Sprintf("%d is outside the bounds of template '%s': min %d, max %d",
+		value, r.template.Name, bounds.Min, bounds.Max))
+}
+
+const (
+	maxVirtualDisks     = 4
+	minVirtualDiskSize  = 10
+	maxVirtualDiskSize  = 500
+	virtualDiskSizeStep = 10
+)
+
+func validateVirtualDiskSize(size int) error {
+	if size < minVirtualDiskSize || size > maxVirtualDiskSize {
 		return NewArgError("size", "MaxSize per Disk: 500 GB. MinSize per Disk 10 GB")
 	}
-	if size%10 != 0 {
+	if size%virtualDiskSizeStep != 0 {
 		return NewArgError("size", "disk size must be a multiple of 10")
+	}
+	return nil
+}
 
+func (r *cloudServerCreateRequestPro) AddVirtualDisk(size int) error {
+	if len(r.VirtualDisks) == maxVirtualDisks {
+		return NewArgError("operation", "max disk count is 4")
+	}
+	if err := validateVirtualDiskSize(size); err != nil {
+		return err
+	}
+	if err := r.checkBounds(virtual_disk_resource_types[len(r.VirtualDisks)], "size", size); err != nil {
+		return err
 	}
 	r.VirtualDisks = append(r.VirtualDisks, CloudServerCreateVirtualDisk{
 		VirtualDiskType: len(r.VirtualDisks),
@@ -285,24 +382,109 @@ func (r *cloudServerCreateRequestPro) AddVirtualDisk(size int) error {
 	return nil
 }
 
+// AddPublicIp adds a purchased IP to the first network adapter, which is reserved for the
+// public network. The first IP added is the primary one.
 func (r *cloudServerCreateRequestPro) AddPublicIp(resourceId int) error {
 	if resourceId == 0 {
 		return NewArgError("resourceId", "it must be > 0")
 	}
-	publicIpAddress := PublicIpAddress{PrimaryIPAddress: "true", PublicIpAddressResourceId: resourceId}
 
+	for i := range r.NetworkAdaptersConfiguration {
+		adapter := &r.NetworkAdaptersConfiguration[i]
+		if adapter.NetworkAdapterType != 0 {
+			continue
+		}
+		for _, publicIpAddress := range adapter.PublicIpAddresses {
+			if publicIpAddress.PublicIpAddressResourceId == resourceId {
+				return NewArgError("resourceId", fmt.Sprintf("IP %d is already added", resourceId))
+			}
+		}
+		adapter.PublicIpAddresses = append(adapter.PublicIpAddresses,
+			PublicIpAddress{PrimaryIPAddress: "false", PublicIpAddressResourceId: resourceId})
+		return nil
+	}
+
+	publicIpAddress := PublicIpAddress{PrimaryIPAddress: "true", PublicIpAddressResourceId: resourceId}
 	r.NetworkAdaptersConfiguration = append(r.NetworkAdaptersConfiguration, NetworkAdapterCreateConfiguration{
-		NetworkAdapterType: len(r.NetworkAdaptersConfiguration),
+		NetworkAdapterType: 0,
 		PublicIpAddresses:  []PublicIpAddress{publicIpAddress},
 	})
 
 	return nil
 }
 
+// AddVLAN connects the next free private network adapter to a purchased VLAN and configures
+// its private IP. The first network adapter is reserved for the public network.
+func (r *cloudServerCreateRequestPro) AddVLAN(vlanResourceId int, ipAddress string, gateway string, mask string) error {
+	if vlanResourceId == 0 {
+		return NewArgError("vlanResourceId", "it must be > 0")
+	}
+
+	ip := net.ParseIP(ipAddress).To4()
+	if ip == nil {
+		return NewArgError("ipAddress", fmt.Sprintf("'%s' is not an IPv4 address", ipAddress))
+	}
+	subnetMask := net.ParseIP(mask).To4()
+	if subnetMask == nil {
+		return NewArgError("mask", fmt.Sprintf("'%s' is not an IPv4 subnet mask", mask))
+	}
+	network := &net.IPNet{IP: ip.Mask(net.IPMask(subnetMask)), Mask: net.IPMask(subnetMask)}
+	if gateway != "" {
+		gatewayIP := net.ParseIP(gateway).To4()
+		if gatewayIP == nil {
+			return NewArgError("gateway", fmt.Sprintf("'%s' is not an IPv4 address", gateway))
+		}
+		if !network.Contains(gatewayIP) {
+			return NewArgError("gateway", fmt.Sprintf("%s is not in the network %s", gateway, network))
+		}
+	}
+
+	for _, adapter := range r.NetworkAdaptersConfiguration {
+		if adapter.PrivateVLan != nil && adapter.PrivateVLan.VLanResourceId == vlanResourceId {
+			return NewArgError("vlanResourceId", fmt.Sprintf("VLAN %d is already attached to network adapter %d",
+				vlanResourceId, adapter.NetworkAdapterType))
+		}
+	}
+
+	networkAdapterType, err := r.nextNetworkAdapterType(1)
+	if err != nil {
+		return err
+	}
+
+	r.NetworkAdaptersConfiguration = append(r.NetworkAdaptersConfiguration, NetworkAdapterCreateConfiguration{
+		NetworkAdapterType: networkAdapterType,
+		PrivateVLan: &PrivateVLanCreateConfiguration{
+			VLanResourceId: vlanResourceId,
+			PrivateIps:     []privateIP{{Gateway: gateway, IP: ipAddress, SubNetMask: mask}},
+		},
+	})
+
+	return nil
+}
+
+// nextNetworkAdapterType returns the first unused network adapter starting at first.
+func (r *cloudServerCreateRequestPro) nextNetworkAdapterType(first int) (int, error) {
+	for networkAdapterType := first; networkAdapterType < maxNetworkAdapters; networkAdapterType++ {
+		used := false
+		for _, adapter := range r.NetworkAdaptersConfiguration {
+			if adapter.NetworkAdapterType == networkAdapterType {
+				used = true
+			}
+		}
+		if !used {
+			return networkAdapterType, nil
+		}
+	}
+	return 0, NewArgError("operation", "max network adapter count is 3")
+}
+
 func (r *cloudServerCreateRequestPro) SetCPUQuantity(cpu_quantity int) error {
 	if cpu_quantity < 1 {
 		return NewArgError("cpu_quantity", "it must be > 1")
 	}
+	if err := r.checkBounds(RESOURCE_CPU, "cpu_quantity", cpu_quantity); err != nil {
+		return err
+	}
 
 	r.CPUQuantity = cpu_quantity
 	return nil
@@ -312,28 +494,62 @@ func (r *cloudServerCreateRequestPro) SetRAMQuantity(ram_quantity int) error {
 	if ram_quantity < 1 {
 		return NewArgError("ram_quantity", "it must be > 1")
 	}
+	if err := r.checkBounds(RESOURCE_RAM, "ram_quantity", ram_quantity); err != nil {
+		return err
+	}
 
 	r.RAMQuantity = ram_quantity
 	return nil
 }
 
-func (r *cloudServerCreateRequestPro) SetNote(note string) error {
-	if len(note) > 4096 {
+const maxNoteLength = 4096
+
+func validateNote(note string) error {
+	if len(note) > maxNoteLength {
 		return NewArgError("note", "it is too long")
 	}
+	return nil
+}
+
+func (r *cloudServerCreateRequestPro) SetNote(note string) error {
+	if err := validateNote(note); err != nil {
+		return err
+	}
 	r.Note = note
 	return nil
 }
 
+// validateIPv6 checks that IPv6 can be configured on servers created from the template.
+func validateIPv6(template *OSTemplate) error {
+	if template != nil && !template.Ipv6Compatible {
+		return NewArgError("ConfigureIPv6", fmt.Sprintf("template '%s' is not IPv6 compatible", template.Name))
+	}
+	return nil
+}
+
+func (r *cloudServerCreateRequestPro) EnableIPv6() error {
+	if err := validateIPv6(r.template); err != nil {
+		return err
+	}
+	r.ConfigureIPv6 = true
+	return nil
+}
+
 func (r *cloudServerCreateRequestPro) GetServerName() string {
 	return r.Name
 }
 
 func (r *cloudServerCreateRequestPro) GetRequest() interface{} {
 	if len(r.VirtualDisks) == 0 {
+		size := minVirtualDiskSize
+		if r.template != nil {
+			if bounds := r.template.Bounds(RESOURCE_VIRTUAL_DISK); bounds != nil && bounds.Default != 0 {
+				size = bounds.Default
+			}
+		}
 		r.VirtualDisks = append(r.VirtualDisks, CloudServerCreateVirtualDisk{
 			VirtualDiskType: 0,
-			Size:            10,
+			Size:            size,
 		})
 	}
 
@@ -347,6 +563,10 @@ type cloudServerCreateRequestSmart struct {
 	OSTemplateId          int                  `json:"OSTemplateId"`
 	CloudServerSmartType  CloudServerSmartSize `json:"SmartVMWarePackageID"`
 	Note                  string               `json:"Note"`
+	ConfigureIPv6         bool                 `json:"ConfigureIPv6,omitempty"`
+
+	// Template the request is validated against, if known
+	template *OSTemplate
 }
 
 func (r *cloudServerCreateRequestSmart) GetServerName() string {
@@ -358,13 +578,21 @@ func (r *cloudServerCreateRequestSmart) GetRequest() interface{} {
 }
 
 func (r *cloudServerCreateRequestSmart) SetNote(note string) error {
-	if len(note) > 4096 {
-		return NewArgError("note", "it is too long")
+	if err := validateNote(note); err != nil {
+		return err
 	}
 	r.Note = note
 	return nil
 }
 
+func (r *cloudServerCreateRequestSmart) EnableIPv6() error {
+	if err := validateIPv6(r.template); err != nil {
+		return err
+	}
+	r.ConfigureIPv6 = true
+	return nil
+}
+
 type hypervisorsRoot struct {
 	Hypervisors []Hypervisor `json:"Value"`
 }
@@ -383,9 +611,43 @@ type CloudServerCreateVirtualDisk struct {
 	Size            int
 }
 
+// Number of network adapters of a CloudServer PRO
+const maxNetworkAdapters = 3
+
 type NetworkAdapterCreateConfiguration struct {
 	NetworkAdapterType int
-	PublicIpAddresses  []PublicIpAddress
+	PublicIpAddresses  []PublicIpAddress               `json:"PublicIpAddresses,omitempty"`
+	PrivateVLan        *PrivateVLanCreateConfiguration `json:"PrivateVLan,omitempty"`
+}
+
+// PrivateVLanCreateConfiguration connects a network adapter to a VLAN for the create request.
+type PrivateVLanCreateConfiguration struct {
+	VLanResourceId int
+	PrivateIps     []privateIP
+}
+
+// VirtualDisk returns the virtual disk in the VirtualDiskType slot or nil.
+func (s *CloudServerDetails) VirtualDisk(virtualDiskType int) *VirtualDisk {
+	if virtualDiskType < 0 || virtualDiskType >= maxVirtualDisks {
+		return nil
+	}
+	for i := range s.VirtualDisks {
+		if ResourceType(s.VirtualDisks[i].ResourceType) == virtual_disk_resource_types[virtualDiskType] {
+			return &s.VirtualDisks[i]
+		}
+	}
+	return nil
+}
+
+// FreePrivateNetworkAdapter returns the id of the first private network adapter which is
+// not attached to a VLAN.
+func (s *CloudServerDetails) FreePrivateNetworkAdapter() (int, error) {
+	for _, adapter := range s.NetworkAdapters {
+		if adapter.NetworkAdapterType != 0 && adapter.VLan.ResourceId == 0 {
+			return adapter.Id, nil
+		}
+	}
+	return 0, fmt.Errorf("Server %d doesn't have a free network adapter", s.ServerId)
 }
 
 func (s *CloudServerDetails) GetPublicIpAddress() (string, error) {
@@ -445,6 +707,10 @@ func (s *CloudServersServiceOp) Create(requestCreator CloudServerCreator) (*Clou
 		return nil, nil, NewArgError("request", "cannot be nil")
 	}
 
+	if err := validateCreatePassword(requestCreator); err != nil {
+		return nil, nil, err
+	}
+
 	data := struct {
 		Server interface{} `json:"Server"`
 	}{requestCreator.GetRequest()}
@@ -502,6 +768,46 @@ func (s *CloudServersServiceOp) Delete(serverId int) (*Response, error) {
 	return resp, err
 }
 
+// Update name and note of a CloudServer
+func (s *CloudServersServiceOp) Update(serverId int, name string, note string) (*Response, error) {
+	if name == "" {
+		return nil, NewArgError("name", "cannot be empty")
+	}
+	if err := validateNote(note); err != nil {
+		return nil, err
+	}
+
+	servers, resp, err := s.List()
+	if err != nil {
+		return resp, err
+	}
+
+	for _, server := range servers {
+		if server.Name == name && server.ServerId != serverId {
+			return nil, NewArgError("name", fmt.Sprintf("it is already used by server %d", server.ServerId))
+		}
+	}
+
+	data := struct {
+		ServerId int
+		Name     string
+		Note     string
+	}{serverId, name, note}
+
+	req, err := s.client.NewRequest(cloudSeverUpdatePath, data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err = s.client.Do(req, nil)
+	if err != nil {
+		return resp, err
+	}
+
+	return resp, err
+}
+
 const (
 	// maxRetries is the amount of times we can fail before deciding
 	// the check is a total failure.
@@ -510,6 +816,9 @@ const (
 
 // WaitForServerStatus waits for a cloud servers status
 func WaitForServerStatus(client *Client, serverId int, status ServerStatus) error {
+	if client.DryRun {
+		return nil
+	}
 	completed := false
 	failCount := 0
 	for !completed {
@@ -533,8 +842,36 @@ func WaitForServerStatus(client *Client, serverId int, status ServerStatus) erro
 	return nil
 }
 
-// WaitForServerWithName waits for a server with specified name appears in the list
+// waitForServerDetails waits until the details of a cloud server satisfy done
+func waitForServerDetails(client *Client, serverId int, done func(*CloudServerDetails) bool) error {
+	if client.DryRun {
+		return nil
+	}
+	failCount := 0
+	for {
+		server_details, _, err := client.CloudServers.Get(serverId)
+
+		if err != nil {
+			if failCount <= maxRetries {
+				failCount++
+				continue
+			}
+			return err
+		}
+
+		if done(server_details) {
+			return nil
+		}
+		time.Sleep(10 * time.Second)
+	}
+}
+
+// WaitForServerWithName waits for a server with specified name appears in the list. In dry-run
+// mode a server without id is returned.
 func WaitForServerWithName(client *Client, serverName string) (*CloudServer, error) {
+	if client.DryRun {
+		return &CloudServer{Name: serverName}, nil
+	}
 	completed := false
 	failCount := 0
 	var server *CloudServer
@@ -563,14 +900,21 @@ func WaitForServerWithName(client *Client, serverName string) (*CloudServer, err
 }
 
 func WaitForServerCreationDone(client *Client, serverId int) error {
-	completed := false
-	for !completed {
+	return WaitForServerJobsDone(client, serverId)
+}
+
+// WaitForServerJobsDone waits until a cloud server has no active jobs
+func WaitForServerJobsDone(client *Client, serverId int) error {
+	if client.DryRun {
+		return nil
+	}
+	for {
 		all_jobs, _, err := client.DataCenters.GetJobs()
 		if err != nil {
 			return err
 		}
 
-		server_jobs := make([]ActiveJob, len(all_jobs))
+		server_jobs := make([]ActiveJob, 0, len(all_jobs))
 		for _, job := range all_jobs {
 			if job.ServerId == serverId {
 				server_jobs = append(server_jobs, job)
@@ -579,7 +923,7 @@ func WaitForServerCreationDone(client *Client, serverId int) error {
 
 		if len(server_jobs) == 0 {
 			log.Printf("[INFO] No active jobs for server %d", serverId)
-			completed = true
+			return nil
 		}
 
 		for _, job := range server_jobs {
@@ -589,6 +933,4 @@ func WaitForServerCreationDone(client *Client, serverId int) error {
 
 		time.Sleep(15 * time.Second)
 	}
-
-	return nil
 }
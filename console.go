@@ -0,0 +1,250 @@
+package goarubacloud
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/websocket"
+)
+
+// ConsoleService is an interface for accessing the VNC console of Cloud Servers
+type ConsoleService interface {
+	GetVNCEndpoint(serverId int) (*VNCEndpoint, *Response, error)
+}
+
+// ConsoleServiceOp resolves the VNC consoles of Cloud Servers.
+type ConsoleServiceOp struct {
+	client *Client
+}
+
+var _ ConsoleService = &ConsoleServiceOp{}
+
+// VNCEndpoint is the address of the VNC console of a Cloud Server.
+type VNCEndpoint struct {
+	ServerId int
+	Host     string
+	Port     int
+}
+
+// Address returns the endpoint as host:port.
+func (e *VNCEndpoint) Address() string {
+	return net.JoinHostPort(e.Host, strconv.Itoa(e.Port))
+}
+
+// GetVNCEndpoint returns the VNC console endpoint of a Cloud Server. The host is
+// Client.ConsoleHost, or the API host if it is not set.
+func (s *ConsoleServiceOp) GetVNCEndpoint(serverId int) (*VNCEndpoint, *Response, error) {
+	serverDetails, resp, err := s.client.CloudServers.Get(serverId)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	if serverDetails.VncPort == 0 {
+		return nil, resp, fmt.Errorf("Server %d doesn't have a VNC console", serverId)
+	}
+
+	host := s.client.ConsoleHost
+	if host == "" {
+		host = s.client.BaseURL.Hostname()
+	}
+
+	return &VNCEndpoint{ServerId: serverId, Host: host, Port: serverDetails.VncPort}, resp, nil
+}
+
+// ConsoleProxy exposes a VNC console on a local listener, either as plain TCP for desktop
+// VNC clients or as WebSocket for noVNC.
+type ConsoleProxy struct {
+	// Address of the VNC server, e.g. VNCEndpoint.Address()
+	Target string
+
+	// Optional function used to connect to the target. Defaults to net.Dial
+	Dial func(network, address string) (net.Conn, error)
+
+	// Origins of the web pages allowed to use the WebSocket listener, e.g.
+	// "http://localhost:6080". Defaults to pages served from the listener's own host, so
+	// other pages open in the browser can't take over the console
+	AllowedOrigins []string
+
+	mu        sync.Mutex
+	listeners []net.Listener
+	conns     map[io.Closer]struct{}
+}
+
+// NewConsoleProxy returns a new ConsoleProxy for the VNC server at target.
+func NewConsoleProxy(target string) *ConsoleProxy {
+	return &ConsoleProxy{Target: target}
+}
+
+// ListenTCP accepts plain TCP connections on address and forwards them to the target. It
+// returns the address of the listener, so address may use port 0.
+func (p *ConsoleProxy) ListenTCP(address string) (net.Addr, error) {
+	listener, err := p.listen(address)
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go p.forward(conn)
+		}
+	}()
+
+	return listener.Addr(), nil
+}
+
+// ListenWebSocket accepts WebSocket connections on address and forwards them to the target
+// using binary frames, as expected by noVNC.
+func (p *ConsoleProxy) ListenWebSocket(address string) (net.Addr, error) {
+	listener, err := p.listen(address)
+	if err != nil {
+		return nil, err
+	}
+
+	server := websocket.Server{
+		Handshake: func(config *websocket.Config, req *http.Request) error {
+			origin, err := websocket.Origin(config, req)
+			if err != nil {
+				return err
+			}
+			if !p.allowedOrigin(origin, req) {
+				return fmt.Errorf("Origin %s is not allowed", origin)
+			}
+			config.Origin = origin
+
+			protocols := config.Protocol
+			config.Protocol = nil
+			for _, protocol := range protocols {
+				if protocol == "binary" {
+					config.Protocol = []string{protocol}
+				}
+			}
+			return nil
+		},
+		Handler: func(conn *websocket.Conn) {
+			conn.PayloadType = websocket.BinaryFrame
+			p.forward(conn)
+		},
+	}
+
+	go func() {
+		err := http.Serve(listener, server)
+		if err != nil && !errors.Is(err, net.ErrClosed) {
+			log.Printf("[ERROR] VNC console WebSocket listener on %s failed: %s\n", listener.Addr(), err)
+		}
+	}()
+
+	return listener.Addr(), nil
+}
+
+// allowedOrigin checks the origin of a WebSocket connection. Connections without origin are
+// not made by browsers and are allowed.
+func (p *ConsoleProxy) allowedOrigin(origin *url.URL, req *http.Request) bool {
+	if origin == nil {
+		return true
+	}
+	if len(p.AllowedOrigins) == 0 {
+		return strings.EqualFold(origin.Host, req.Host)
+	}
+
+	for _, allowed := range p.AllowedOrigins {
+		allowedOrigin, err := url.Parse(allowed)
+		if err == nil && strings.EqualFold(allowedOrigin.Scheme, origin.Scheme) &&
+			strings.EqualFold(allowedOrigin.Host, origin.Host) {
+			return true
+		}
+	}
+	return false
+}
+
+// Close stops all listeners of the proxy and closes the proxied connections.
+func (p *ConsoleProxy) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var err error
+	for _, listener := range p.listeners {
+		if cerr := listener.Close(); err == nil {
+			err = cerr
+		}
+	}
+	p.listeners = nil
+
+	for conn := range p.conns {
+		conn.Close()
+	}
+	p.conns = nil
+	return err
+}
+
+func (p *ConsoleProxy) listen(address string) (net.Listener, error) {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	p.listeners = append(p.listeners, listener)
+	p.mu.Unlock()
+
+	log.Printf("[INFO] VNC console of %s available on %s\n", p.Target, listener.Addr())
+	return listener, nil
+}
+
+func (p *ConsoleProxy) track(conn io.Closer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.conns == nil {
+		p.conns = map[io.Closer]struct{}{}
+	}
+	p.conns[conn] = struct{}{}
+}
+
+func (p *ConsoleProxy) untrack(conn io.Closer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.conns, conn)
+	conn.Close()
+}
+
+func (p *ConsoleProxy) forward(client io.ReadWriteCloser) {
+	p.track(client)
+	defer p.untrack(client)
+
+	dial := p.Dial
+	if dial == nil {
+		dial = net.Dial
+	}
+
+	target, err := dial("tcp", p.Target)
+	if err != nil {
+		log.Printf("[ERROR] Unable to connect to VNC server %s: %s\n", p.Target, err)
+		return
+	}
+	p.track(target)
+	defer p.untrack(target)
+
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(target, client)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(client, target)
+		done <- struct{}{}
+	}()
+	<-done
+}
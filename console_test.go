@@ -0,0 +1,150 @@
+package goarubacloud
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+const stubVNCGreeting = "RFB 003.008\n"
+
+// startStubVNCServer accepts connections, sends the VNC greeting and echoes all data.
+func startStubVNCServer(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.WriteString(conn, stubVNCGreeting)
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+func checkConsoleRoundTrip(t *testing.T, conn net.Conn) {
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	reader := bufio.NewReader(conn)
+
+	greeting, err := reader.ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if greeting != stubVNCGreeting {
+		t.Errorf("greeting = %q, expected %q", greeting, stubVNCGreeting)
+	}
+
+	if _, err := io.WriteString(conn, "ping\n"); err != nil {
+		t.Fatal(err)
+	}
+	echo, err := reader.ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if echo != "ping\n" {
+		t.Errorf("echo = %q, expected %q", echo, "ping\n")
+	}
+}
+
+func checkConsoleClosed(t *testing.T, conn net.Conn) {
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	_, err := io.ReadAll(conn)
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		t.Errorf("connection still open after Close: %s", err)
+	}
+}
+
+func TestConsoleProxy_ListenTCP(t *testing.T) {
+	proxy := NewConsoleProxy(startStubVNCServer(t))
+	defer proxy.Close()
+
+	addr, err := proxy.ListenTCP("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := net.Dial("tcp", addr.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	checkConsoleRoundTrip(t, conn)
+
+	if err := proxy.Close(); err != nil {
+		t.Fatal(err)
+	}
+	checkConsoleClosed(t, conn)
+}
+
+func TestConsoleProxy_ListenWebSocket(t *testing.T) {
+	proxy := NewConsoleProxy(startStubVNCServer(t))
+	defer proxy.Close()
+
+	addr, err := proxy.ListenWebSocket("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := websocket.Dial("ws://"+addr.String()+"/", "binary", "http://"+addr.String()+"/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if protocol := conn.Config().Protocol; len(protocol) != 1 || protocol[0] != "binary" {
+		t.Errorf("protocol = %v, expected [binary]", protocol)
+	}
+	conn.PayloadType = websocket.BinaryFrame
+
+	checkConsoleRoundTrip(t, conn)
+
+	if err := proxy.Close(); err != nil {
+		t.Fatal(err)
+	}
+	checkConsoleClosed(t, conn)
+}
+
+func TestConsoleProxy_ListenWebSocketOrigins(t *testing.T) {
+	proxy := NewConsoleProxy(startStubVNCServer(t))
+	defer proxy.Close()
+
+	addr, err := proxy.ListenWebSocket("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	url := "ws://" + addr.String() + "/"
+
+	if _, err := websocket.Dial(url, "binary", "http://evil.example.com/"); err == nil {
+		t.Error("connection from another origin was accepted")
+	}
+
+	proxy.AllowedOrigins = []string{"http://localhost:6080"}
+	if _, err := websocket.Dial(url, "binary", "http://"+addr.String()+"/"); err == nil {
+		t.Error("connection from an origin not in AllowedOrigins was accepted")
+	}
+
+	conn, err := websocket.Dial(url, "binary", "http://localhost:6080/vnc.html")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.PayloadType = websocket.BinaryFrame
+
+	checkConsoleRoundTrip(t, conn)
+}
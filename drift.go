@@ -0,0 +1,281 @@
+package goarubacloud
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+type DriftType int
+
+const (
+	DRIFT_SERVER_ADDED DriftType = 1 + iota
+	DRIFT_SERVER_DELETED
+	DRIFT_SERVER_CHANGED
+	DRIFT_DISK_ADDED
+	DRIFT_DISK_REMOVED
+	DRIFT_DISK_RESIZED
+	DRIFT_IP_MOVED
+	DRIFT_VLAN_JOINED
+	DRIFT_VLAN_LEFT
+	DRIFT_SERVER_MISSING
+)
+
+var drift_types = [...]string{
+	"SERVER_ADDED",
+	"SERVER_DELETED",
+	"SERVER_CHANGED",
+	"DISK_ADDED",
+	"DISK_REMOVED",
+	"DISK_RESIZED",
+	"IP_MOVED",
+	"VLAN_JOINED",
+	"VLAN_LEFT",
+	"SERVER_MISSING",
+}
+
+// String returns the name of the DriftType.
+func (m DriftType) String() string {
+	return drift_types[m-1]
+}
+
+// MarshalText encodes the DriftType by its name.
+func (m DriftType) MarshalText() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// Drift is a single difference between the saved and the live state.
+type Drift struct {
+	Type       DriftType
+	ServerId   int    `json:",omitempty"`
+	ServerName string `json:",omitempty"`
+
+	// Changed field, disk number, IP address or VLAN name
+	Field string `json:",omitempty"`
+	Saved string `json:",omitempty"`
+	Live  string `json:",omitempty"`
+}
+
+// String returns a human readable description of the Drift.
+func (d Drift) String() string {
+	server := fmt.Sprintf("server '%s'", d.ServerName)
+	if d.ServerId != 0 {
+		server = fmt.Sprintf("server '%s' (%d)", d.ServerName, d.ServerId)
+	}
+
+	switch d.Type {
+	case DRIFT_SERVER_ADDED:
+		return fmt.Sprintf("%s was created", server)
+	case DRIFT_SERVER_DELETED:
+		return fmt.Sprintf("%s was deleted", server)
+	case DRIFT_SERVER_CHANGED:
+		return fmt.Sprintf("%s: %s changed from %s to %s", server, d.Field, d.Saved, d.Live)
+	case DRIFT_DISK_ADDED:
+		return fmt.Sprintf("%s: disk %s of %s GB was added", server, d.Field, d.Live)
+	case DRIFT_DISK_REMOVED:
+		return fmt.Sprintf("%s: disk %s of %s GB was removed", server, d.Field, d.Saved)
+	case DRIFT_DISK_RESIZED:
+		return fmt.Sprintf("%s: disk %s was resized from %s GB to %s GB", server, d.Field, d.Saved, d.Live)
+	case DRIFT_IP_MOVED:
+		return fmt.Sprintf("IP %s moved from server '%s' to server '%s'", d.Field, d.Saved, d.Live)
+	case DRIFT_VLAN_JOINED:
+		return fmt.Sprintf("%s joined VLAN '%s'", server, d.Field)
+	case DRIFT_VLAN_LEFT:
+		return fmt.Sprintf("%s left VLAN '%s'", server, d.Field)
+	case DRIFT_SERVER_MISSING:
+		return fmt.Sprintf("%s is declared but does not exist", server)
+	}
+	return fmt.Sprintf("%s: %s", server, d.Type)
+}
+
+// DriftReport lists the differences between the saved and the live state.
+type DriftReport struct {
+	Drifts []Drift
+}
+
+// HasDrift reports whether any difference was found.
+func (r *DriftReport) HasDrift() bool {
+	return len(r.Drifts) > 0
+}
+
+// String returns the report as human readable text, one difference per line.
+func (r *DriftReport) String() string {
+	if !r.HasDrift() {
+		return "No drift detected\n"
+	}
+
+	var buffer bytes.Buffer
+	for _, drift := range r.Drifts {
+		buffer.WriteString(drift.String())
+		buffer.WriteString("\n")
+	}
+	return buffer.String()
+}
+
+// JSON returns the report as JSON.
+func (r *DriftReport) JSON() ([]byte, error) {
+	return json.MarshalIndent(r, "", "  ")
+}
+
+// DetectDrift compares a saved Inventory to the live one. Servers are matched by id, so
+// renamed servers are reported as changed.
+func DetectDrift(saved *Inventory, live *Inventory) *DriftReport {
+	report := new(DriftReport)
+
+	for i := range saved.Servers {
+		savedServer := &saved.Servers[i]
+		liveServer := live.ServerById(savedServer.ServerId)
+		if liveServer == nil {
+			report.add(Drift{Type: DRIFT_SERVER_DELETED, ServerId: savedServer.ServerId, ServerName: savedServer.Name})
+			continue
+		}
+		report.compareServers(savedServer, liveServer, false)
+	}
+
+	for i := range live.Servers {
+		if saved.ServerById(live.Servers[i].ServerId) == nil {
+			report.add(Drift{Type: DRIFT_SERVER_ADDED, ServerId: live.Servers[i].ServerId, ServerName: live.Servers[i].Name})
+		}
+	}
+
+	for _, savedIP := range saved.PurchasedIPs {
+		for _, liveIP := range live.PurchasedIPs {
+			if liveIP.Value == savedIP.Value && liveIP.ServerId != savedIP.ServerId {
+				report.add(Drift{Type: DRIFT_IP_MOVED, ServerId: liveIP.ServerId,
+					ServerName: serverName(live, liveIP.ServerId), Field: liveIP.Value,
+					Saved: serverName(saved, savedIP.ServerId), Live: serverName(live, liveIP.ServerId)})
+			}
+		}
+	}
+
+	return report
+}
+
+// DetectSpecDrift compares a DesiredState to the live Inventory. Servers are matched by
+// name and only the declared CPU, RAM, disks and VLANs are compared.
+func DetectSpecDrift(spec *DesiredState, live *Inventory) *DriftReport {
+	report := new(DriftReport)
+	declared := map[string]bool{}
+
+	for _, server := range spec.Servers {
+		declared[server.Name] = true
+		liveServer := live.Server(server.Name)
+		if liveServer == nil {
+			report.add(Drift{Type: DRIFT_SERVER_MISSING, ServerName: server.Name})
+			continue
+		}
+		report.compareServers(server.details(), liveServer, true)
+	}
+
+	for _, server := range live.Servers {
+		if !declared[server.Name] {
+			report.add(Drift{Type: DRIFT_SERVER_ADDED, ServerId: server.ServerId, ServerName: server.Name})
+		}
+	}
+
+	return report
+}
+
+func (r *DriftReport) add(drift Drift) {
+	r.Drifts = append(r.Drifts, drift)
+}
+
+func (r *DriftReport) compareServers(saved *CloudServerDetails, live *CloudServerDetails, declaredOnly bool) {
+	changed := func(field string, savedValue int, liveValue int) {
+		if savedValue == liveValue || (declaredOnly && savedValue == 0) {
+			return
+		}
+		r.add(Drift{Type: DRIFT_SERVER_CHANGED, ServerId: live.ServerId, ServerName: live.Name,
+			Field: field, Saved: strconv.Itoa(savedValue), Live: strconv.Itoa(liveValue)})
+	}
+
+	if !declaredOnly && saved.Name != live.Name {
+		r.add(Drift{Type: DRIFT_SERVER_CHANGED, ServerId: live.ServerId, ServerName: live.Name,
+			Field: "Name", Saved: saved.Name, Live: live.Name})
+	}
+	changed("CPUQuantity", saved.CPUQuantity.Quantity, live.CPUQuantity.Quantity)
+	changed("RAMQuantity", saved.RAMQuantity.Quantity, live.RAMQuantity.Quantity)
+
+	// Disks are matched by slot, so a removed disk doesn't shift the following ones
+	if !declaredOnly || len(saved.VirtualDisks) > 0 {
+		for slot := 0; slot < maxVirtualDisks; slot++ {
+			savedDisk := saved.VirtualDisk(slot)
+			liveDisk := live.VirtualDisk(slot)
+			drift := Drift{ServerId: live.ServerId, ServerName: live.Name, Field: strconv.Itoa(slot)}
+			switch {
+			case savedDisk == nil && liveDisk == nil:
+				continue
+			case liveDisk == nil:
+				drift.Type = DRIFT_DISK_REMOVED
+				drift.Saved = strconv.Itoa(savedDisk.Size)
+			case savedDisk == nil:
+				drift.Type = DRIFT_DISK_ADDED
+				drift.Live = strconv.Itoa(liveDisk.Size)
+			case savedDisk.Size != liveDisk.Size:
+				drift.Type = DRIFT_DISK_RESIZED
+				drift.Saved = strconv.Itoa(savedDisk.Size)
+				drift.Live = strconv.Itoa(liveDisk.Size)
+			default:
+				continue
+			}
+			r.add(drift)
+		}
+	}
+
+	savedVLANs := vlanNames(saved)
+	liveVLANs := vlanNames(live)
+	if declaredOnly && len(savedVLANs) == 0 {
+		return
+	}
+	for _, adapter := range live.NetworkAdapters {
+		if name := adapter.VLan.Name; name != "" && !savedVLANs[name] {
+			r.add(Drift{Type: DRIFT_VLAN_JOINED, ServerId: live.ServerId, ServerName: live.Name, Field: name})
+		}
+	}
+	for _, adapter := range saved.NetworkAdapters {
+		if name := adapter.VLan.Name; name != "" && !liveVLANs[name] {
+			r.add(Drift{Type: DRIFT_VLAN_LEFT, ServerId: live.ServerId, ServerName: live.Name, Field: name})
+		}
+	}
+}
+
+// details returns the declared part of the server as CloudServerDetails.
+func (s *DesiredServer) details() *CloudServerDetails {
+	details := &CloudServerDetails{
+		Name:        s.Name,
+		CPUQuantity: CPUQuantity{Quantity: s.CPUQuantity},
+		RAMQuantity: RAMQuantity{Quantity: s.RAMQuantity},
+	}
+	for slot, size := range s.Disks {
+		if slot >= maxVirtualDisks {
+			break
+		}
+		details.VirtualDisks = append(details.VirtualDisks,
+			VirtualDisk{ResourceType: int(virtual_disk_resource_types[slot]), Size: size})
+	}
+	for _, name := range s.VLANs {
+		details.NetworkAdapters = append(details.NetworkAdapters, NetworkAdapter{VLan: PurchasedVLAN{Name: name}})
+	}
+	return details
+}
+
+func vlanNames(server *CloudServerDetails) map[string]bool {
+	names := map[string]bool{}
+	for _, adapter := range server.NetworkAdapters {
+		if adapter.VLan.Name != "" {
+			names[adapter.VLan.Name] = true
+		}
+	}
+	return names
+}
+
+func serverName(inventory *Inventory, serverId int) string {
+	if serverId == 0 {
+		return ""
+	}
+	if server := inventory.ServerById(serverId); server != nil {
+		return server.Name
+	}
+	return strconv.Itoa(serverId)
+}
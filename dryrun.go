@@ -0,0 +1,53 @@
+package goarubacloud
+
+import (
+	"log"
+	"net/http"
+	"strings"
+)
+
+// DryRunResourceId is the id of IPs and VLANs purchased in dry-run mode. Requests using them,
+// e.g. a server creation with a purchased IP, show it in place of the real id.
+const DryRunResourceId = -1
+
+// DryRunRequest is a changing request which was not sent because Client.DryRun is set.
+type DryRunRequest struct {
+	Action string
+	URL    string
+
+	// JSON body of the request with credentials and passwords redacted
+	Body string
+}
+
+// isReadAction reports whether an action only reads data. All other actions change resources.
+func isReadAction(action string) bool {
+	return strings.HasPrefix(action, "Get")
+}
+
+// DryRunRequests returns the requests recorded in dry-run mode since the last call.
+func (c *Client) DryRunRequests() []DryRunRequest {
+	c.dryRunMu.Lock()
+	defer c.dryRunMu.Unlock()
+
+	requests := c.dryRunRequests
+	c.dryRunRequests = nil
+	return requests
+}
+
+// dryRun records a changing request instead of sending it. The returned Response has no
+// http.Response.
+func (c *Client) dryRun(req *http.Request, action string) (*Response, error) {
+	body, err := requestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	request := DryRunRequest{Action: action, URL: req.URL.String(), Body: redactJSON(body)}
+	log.Printf("[INFO] Dry run, not sending %s: %s\n", action, request.Body)
+
+	c.dryRunMu.Lock()
+	c.dryRunRequests = append(c.dryRunRequests, request)
+	c.dryRunMu.Unlock()
+
+	return &Response{RequestId: requestIdOf(req.Context()), DryRun: &request}, nil
+}
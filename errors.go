@@ -1,6 +1,9 @@
 package goarubacloud
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 // ArgError is an error that represents an error with an input to goarubacloud. It
 // identifies the argument and the cause (if possible).
@@ -23,3 +26,50 @@ func (e *ArgError) Error() string {
 	return fmt.Sprintf("%s is invalid because %s", e.arg, e.reason)
 }
 
+// PreflightError lists every violation found by a preflight check.
+type PreflightError struct {
+	Violations []PreflightViolation
+}
+
+var _ error = &PreflightError{}
+
+func (e *PreflightError) Error() string {
+	reasons := make([]string, len(e.Violations))
+	for i, violation := range e.Violations {
+		reasons[i] = violation.String()
+	}
+	return fmt.Sprintf("preflight check failed: %s", strings.Join(reasons, "; "))
+}
+
+// HypervisorMismatchError reports a template which doesn't belong to a hypervisor compatible
+// with the create request.
+type HypervisorMismatchError struct {
+	TemplateId     int
+	TemplateName   string
+	HypervisorType HypervisorType
+	Compatible     []HypervisorType
+
+	// Templates of compatible hypervisors which can be used instead
+	Alternatives []OSTemplate
+}
+
+var _ error = &HypervisorMismatchError{}
+
+func (e *HypervisorMismatchError) Error() string {
+	compatible := make([]string, len(e.Compatible))
+	for i, hypervisorType := range e.Compatible {
+		compatible[i] = hypervisorType.String()
+	}
+
+	message := fmt.Sprintf("template %d ('%s') belongs to hypervisor '%s', the request needs one of: %s",
+		e.TemplateId, e.TemplateName, e.HypervisorType, strings.Join(compatible, ", "))
+
+	if len(e.Alternatives) > 0 {
+		alternatives := make([]string, len(e.Alternatives))
+		for i, template := range e.Alternatives {
+			alternatives[i] = fmt.Sprintf("%d ('%s')", template.Id, template.Description)
+		}
+		message += fmt.Sprintf(". Alternatives: %s", strings.Join(alternatives, ", "))
+	}
+	return message
+}
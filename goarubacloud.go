@@ -10,8 +10,9 @@ import (
 	"net/http"
 	"net/url"
 	"os"
-
+	"path"
 	"strings"
+	"sync"
 
 	"github.com/hashicorp/logutils"
 )
@@ -59,6 +60,19 @@ type Client struct {
 	// User agent for client
 	UserAgent string
 
+	// ApplicationId sent with every request. Defaults to the name of the action
+	ApplicationId string
+
+	// Host serving the VNC consoles. Defaults to the API host
+	ConsoleHost string
+
+	// Account limits enforced by CloudServers.Preflight
+	AccountLimits AccountLimits
+
+	// Changing requests are not sent but returned in Response.DryRun and recorded for
+	// DryRunRequests. Read requests are still sent and the wait helpers return immediately
+	DryRun bool
+
 	// Services used for communicating with the API
 	DataCenters        DataCentersService
 	Hypervisors        HypervisorsService
@@ -68,9 +82,23 @@ type Client struct {
 	Snapshots          SnapshotsService
 	PurchasedIPs       PurchasedIPsService
 	VLANs              VLANsService
+	Tags               TagsService
+	Templates          TemplatesService
+	Console            ConsoleService
+	Renewals           RenewalsService
+	SmartPackages      SmartPackagesService
 
 	// Optional function called after every successful request made to the Arubacloud API
 	onRequestCompleted RequestCompletionCallback
+
+	// Optional response cache, see EnableCache
+	cache *responseCache
+
+	dryRunMu       sync.Mutex
+	dryRunRequests []DryRunRequest
+
+	// Optional token session, see EnableSessions
+	session *session
 }
 
 // RequestCompletionCallback defines the type of the request callback function
@@ -79,6 +107,12 @@ type RequestCompletionCallback func(*http.Request, *http.Response)
 // Response is a Arubacloud API response. This wraps the standard http.Response returned from Arubacloud.
 type Response struct {
 	*http.Response
+
+	// Unique id of the API call, sent as RequestId
+	RequestId string
+
+	// Request which was not sent because Client.DryRun is set
+	DryRun *DryRunRequest
 }
 
 // An ErrorResponse reports the error caused by an API request
@@ -93,6 +127,9 @@ type ErrorResponse struct {
 
 	// ResultCode returned from the API
 	ResultCode int `json:"ResultCode"`
+
+	// RequestId of the failed call
+	RequestId string `json:"-"`
 }
 
 // NewClient returns a new Arubacloud API client.
@@ -122,24 +159,42 @@ func NewClient(datacenter DataCenterRegion, username, password string) *Client {
 	client.Snapshots = &SnapshotsServiceOp{client: client}
 	client.PurchasedIPs = &PurchasedIPsServiceOp{client: client}
 	client.VLANs = &VLANsServiceOp{client: client}
+	client.Tags = &TagsServiceOp{client: client}
+	client.Templates = &TemplatesServiceOp{client: client}
+	client.Console = &ConsoleServiceOp{client: client}
+	client.Renewals = &RenewalsServiceOp{client: client}
+	client.SmartPackages = &SmartPackagesServiceOp{client: client}
 
 	return client
 }
 
-// NewRequest creates an API request
+// NewRequest creates an API request with a new RequestId
 func (c *Client) NewRequest(action string, body interface{}) (*http.Request, error) {
 	callUrl := fmt.Sprintf("%s/%s", c.BaseURL.String(), action)
 
+	requestId, err := newRequestId()
+	if err != nil {
+		return nil, err
+	}
+	applicationId := c.ApplicationId
+	if applicationId == "" {
+		applicationId = action
+	}
+
+	credentials, err := c.credentials(action)
+	if err != nil {
+		return nil, err
+	}
+
 	requestMap := map[string]interface{}{
-		"ApplicationId": action,
-		"RequestId":     action,
-		"SessionId":     action,
-		"Username":      c.Username,
-		"Password":      c.Password,
+		"ApplicationId": applicationId,
+		"RequestId":     requestId,
+	}
+	for k, v := range credentials {
+		requestMap[k] = v
 	}
 
 	var buffer []byte
-	var err error
 	if body != nil {
 		buffer, err = json.Marshal(body)
 		if err != nil {
@@ -166,7 +221,7 @@ func (c *Client) NewRequest(action string, body interface{}) (*http.Request, err
 	}
 	bodyBuffer := bytes.NewBuffer(buffer)
 
-	log.Printf("[DEBUG] Request: %s\n", bodyBuffer.String())
+	log.Printf("[DEBUG] Request: %s\n", redactJSON(buffer))
 	req, err := http.NewRequest("POST", callUrl, bodyBuffer)
 	if err != nil {
 		return nil, err
@@ -175,7 +230,7 @@ func (c *Client) NewRequest(action string, body interface{}) (*http.Request, err
 	req.Header.Add("Content-Type", mediaType)
 	req.Header.Add("Accept", mediaType)
 	req.Header.Add("User-Agent", c.UserAgent)
-	return req, nil
+	return req.WithContext(withRequestId(req.Context(), requestId)), nil
 }
 
 // OnRequestCompleted sets the API request completion callback
@@ -184,8 +239,8 @@ func (c *Client) OnRequestCompleted(rc RequestCompletionCallback) {
 }
 
 // newResponse creates a new Response for the provided http.Response
-func newResponse(r *http.Response) *Response {
-	response := Response{Response: r}
+func newResponse(r *http.Response, requestId string) *Response {
+	response := Response{Response: r, RequestId: requestId}
 
 	return &response
 }
@@ -194,31 +249,40 @@ func newResponse(r *http.Response) *Response {
 // pointed to by v, or returned as an error if an API error has occurred. If v implements the io.Writer interface,
 // the raw response will be written to v, without attempting to decode it.
 func (c *Client) Do(req *http.Request, v interface{}) (*Response, error) {
-	resp, err := c.client.Do(req)
-	if err != nil {
-		return nil, err
+	action := path.Base(req.URL.Path)
+	if c.DryRun && !isReadAction(action) {
+		return c.dryRun(req, action)
 	}
-	if c.onRequestCompleted != nil {
-		c.onRequestCompleted(req, resp)
-	}
-
-	defer func() {
-		if rerr := resp.Body.Close(); err == nil {
-			err = rerr
-		}
-	}()
 
-	response := newResponse(resp)
-
-	data, err := ioutil.ReadAll(resp.Body)
+	var resp *http.Response
+	var data []byte
+	var err error
+	if c.cache != nil {
+		resp, data, err = c.cache.fetch(req, action, c.send)
+	} else {
+		resp, data, err = c.send(req)
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	log.Printf("[DEBUG] Response:%s\n", bytes.NewBuffer(data).String())
+	response := newResponse(resp, requestIdOf(req.Context()))
+
+	log.Printf("[DEBUG] Response to %s:%s\n", response.RequestId, redactJSON(data))
 
 	err = CheckResponse(resp, data)
 	if err != nil {
+		if errorResponse, ok := err.(*ErrorResponse); ok {
+			errorResponse.RequestId = response.RequestId
+		}
+		if c.session != nil && action != userAuthenticationTokenPath && !isRetry(req.Context()) &&
+			isAuthenticationError(err) {
+			retry, err := c.withNewSession(req)
+			if err != nil {
+				return response, err
+			}
+			return c.Do(retry, v)
+		}
 		return response, err
 	}
 
@@ -239,7 +303,34 @@ func (c *Client) Do(req *http.Request, v interface{}) (*Response, error) {
 	return response, err
 }
 
+// send makes the HTTP request and reads the response body
+func (c *Client) send(req *http.Request) (resp *http.Response, data []byte, err error) {
+	resp, err = c.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	if c.onRequestCompleted != nil {
+		c.onRequestCompleted(req, resp)
+	}
+
+	defer func() {
+		if rerr := resp.Body.Close(); err == nil {
+			err = rerr
+		}
+	}()
+
+	data, err = ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return resp, data, nil
+}
+
 func (r *ErrorResponse) Error() string {
+	if r.RequestId != "" {
+		return fmt.Sprintf("%s. Result code: %d. Request id: %s", r.Message, r.ResultCode, r.RequestId)
+	}
 	return fmt.Sprintf("%s. Result code: %d", r.Message, r.ResultCode)
 }
 
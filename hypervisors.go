@@ -12,6 +12,7 @@ const hypervisorsPath = "GetHypervisors"
 type HypervisorsService interface {
 	GetHypervisors() ([]Hypervisor, *Response, error)
 	FindOsTemplate(HypervisorType, string) (*OSTemplate, error)
+	ResolveTemplate(CloudServerCreator) (*OSTemplate, error)
 }
 
 // HypervisorsServiceOp handles communication with the Hypervisor related methods of the
@@ -59,13 +60,112 @@ func (s *HypervisorsServiceOp)FindOsTemplate(hypervisorType HypervisorType, name
 	}
 
 	for _, template := range hypervisor.Templates {
-		if template.Description == name {
+		if template.Description == name || (template.IsPrivate() && template.Name == name) {
 			return &template, nil
 		}
 	}
 	return nil, fmt.Errorf("Hypervisor '%s' doesn't support template with name '%s'",hypervisorType, name)
 }
 
+// ResolveTemplate looks up the OSTemplateId of a create request and verifies that the template
+// belongs to a hypervisor compatible with the request type. Otherwise a
+// *HypervisorMismatchError listing the valid alternatives is returned. Requests with IPv6
+// enabled need an Ipv6Compatible template. PRO requests are validated against the
+// ResourceBounds of the resolved template from then on.
+func (s *HypervisorsServiceOp) ResolveTemplate(requestCreator CloudServerCreator) (*OSTemplate, error) {
+	var templateId int
+	var configureIPv6 bool
+	var proRequest *cloudServerCreateRequestPro
+	var smartRequest *cloudServerCreateRequestSmart
+	var compatible []HypervisorType
+	switch request := requestCreator.(type) {
+	case *cloudServerCreateRequestPro:
+		templateId = request.OSTemplateId
+		configureIPv6 = request.ConfigureIPv6
+		proRequest = request
+		compatible = proHypervisorTypes
+	case *cloudServerCreateRequestSmart:
+		templateId = request.OSTemplateId
+		configureIPv6 = request.ConfigureIPv6
+		smartRequest = request
+		compatible = smartHypervisorTypes
+	default:
+		return nil, NewArgError("requestCreator", "unsupported request type")
+	}
+
+	hypervisors, _, err := s.client.Hypervisors.GetHypervisors()
+	if err != nil {
+		return nil, err
+	}
+
+	template, hypervisor := findOsTemplateById(hypervisors, templateId)
+	if template == nil {
+		return nil, NewArgError("OSTemplateId", fmt.Sprintf("template %d does not exist", templateId))
+	}
+
+	if !hypervisor.HypervisorType.in(compatible) {
+		return nil, &HypervisorMismatchError{
+			TemplateId:     template.Id,
+			TemplateName:   template.Description,
+			HypervisorType: hypervisor.HypervisorType,
+			Compatible:     compatible,
+			Alternatives:   alternativeTemplates(hypervisors, template, compatible),
+		}
+	}
+
+	if configureIPv6 {
+		if err := validateIPv6(template); err != nil {
+			return nil, err
+		}
+	}
+
+	if proRequest != nil {
+		proRequest.template = template
+	}
+	if smartRequest != nil {
+		smartRequest.template = template
+	}
+	return template, nil
+}
+
+// alternativeTemplates returns the enabled templates of the compatible hypervisors with the
+// same description, or the same OS family if there are none.
+func alternativeTemplates(hypervisors []Hypervisor, template *OSTemplate, compatible []HypervisorType) []OSTemplate {
+	var sameDescription, sameFamily []OSTemplate
+	for _, hypervisor := range hypervisors {
+		if !hypervisor.HypervisorType.in(compatible) {
+			continue
+		}
+		for _, candidate := range hypervisor.Templates {
+			if !candidate.Enabled {
+				continue
+			}
+			if candidate.Description == template.Description {
+				sameDescription = append(sameDescription, candidate)
+			} else if candidate.OSFamily == template.OSFamily {
+				sameFamily = append(sameFamily, candidate)
+			}
+		}
+	}
+
+	if len(sameDescription) > 0 {
+		return sameDescription
+	}
+	return sameFamily
+}
+
+// findOsTemplateById returns the template with the given id and its hypervisor.
+func findOsTemplateById(hypervisors []Hypervisor, templateId int) (*OSTemplate, *Hypervisor) {
+	for i := range hypervisors {
+		for j := range hypervisors[i].Templates {
+			if hypervisors[i].Templates[j].Id == templateId {
+				return &hypervisors[i].Templates[j], &hypervisors[i]
+			}
+		}
+	}
+	return nil, nil
+}
+
 type HypervisorType int
 
 const (
@@ -87,6 +187,25 @@ func (m HypervisorType) String() string {
 	return hypervisors[m-1]
 }
 
+// Hypervisor types usable by Cloud Server PRO and SMART create requests
+var proHypervisorTypes = []HypervisorType{Microsoft_Hyper_V, VMWare_Cloud_Pro, Microsoft_Hyper_V_Low_Cost}
+var smartHypervisorTypes = []HypervisorType{VMWare_Cloud_Smart}
+
+func (m HypervisorType) in(hypervisorTypes []HypervisorType) bool {
+	for _, hypervisorType := range hypervisorTypes {
+		if m == hypervisorType {
+			return true
+		}
+	}
+	return false
+}
+
+// requiresPowerOffForResize reports whether servers must be powered off to change their
+// CPU, RAM or disk sizes.
+func (m HypervisorType) requiresPowerOffForResize() bool {
+	return m == Microsoft_Hyper_V || m == Microsoft_Hyper_V_Low_Cost
+}
+
 type Hypervisor struct {
 	HypervisorServerType int
 	HypervisorType       HypervisorType
@@ -126,12 +245,61 @@ type OSTemplate struct {
 }
 
 type ResourceBounds struct {
-	ResourceType int
+	ResourceType ResourceType
 	Default      int
 	Min          int
 	Max          int
 }
 
+type ResourceType int
+
+const (
+	RESOURCE_CPU          ResourceType = 1
+	RESOURCE_RAM          ResourceType = 2
+	RESOURCE_VIRTUAL_DISK ResourceType = 3
+
+	RESOURCE_VIRTUAL_DISK_1 ResourceType = 7
+	RESOURCE_VIRTUAL_DISK_2 ResourceType = 8
+	RESOURCE_VIRTUAL_DISK_3 ResourceType = 9
+)
+
+// Resource types of the virtual disks by VirtualDiskType slot
+var virtual_disk_resource_types = [...]ResourceType{
+	RESOURCE_VIRTUAL_DISK,
+	RESOURCE_VIRTUAL_DISK_1,
+	RESOURCE_VIRTUAL_DISK_2,
+	RESOURCE_VIRTUAL_DISK_3,
+}
+
+// String returns the name of the ResourceType.
+func (m ResourceType) String() string {
+	resource_types := map[ResourceType]string{
+		1: "CPU",
+		2: "RAM",
+		3: "Virtual disk",
+		7: "Virtual disk 1",
+		8: "Virtual disk 2",
+		9: "Virtual disk 3",
+	}
+
+	return resource_types[m]
+}
+
+// Bounds returns the ResourceBounds of the template for the resource type or nil.
+func (t *OSTemplate) Bounds(resourceType ResourceType) *ResourceBounds {
+	for i := range t.ResourceBounds {
+		if t.ResourceBounds[i].ResourceType == resourceType {
+			return &t.ResourceBounds[i]
+		}
+	}
+	return nil
+}
+
+// Contains reports whether the value is within the bounds. Zero bounds are not enforced.
+func (b *ResourceBounds) Contains(value int) bool {
+	return (b.Min == 0 || value >= b.Min) && (b.Max == 0 || value <= b.Max)
+}
+
 type OSTemplateDetails struct {
 	Id           int
 	Name         string
@@ -0,0 +1,89 @@
+package goarubacloud
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// Inventory is a snapshot of the Cloud Servers, purchased IPs and VLANs of a datacenter.
+type Inventory struct {
+	Servers      []CloudServerDetails
+	PurchasedIPs []PurchasedIP
+	VLANs        []PurchasedVLAN
+}
+
+// TakeInventory reads the current state of the datacenter.
+func TakeInventory(client *Client) (*Inventory, error) {
+	servers, _, err := client.CloudServers.List()
+	if err != nil {
+		return nil, err
+	}
+
+	inventory := new(Inventory)
+	for _, server := range servers {
+		details, _, err := client.CloudServers.Get(server.ServerId)
+		if err != nil {
+			return nil, err
+		}
+		inventory.Servers = append(inventory.Servers, *details)
+	}
+
+	inventory.PurchasedIPs, _, err = client.PurchasedIPs.List()
+	if err != nil {
+		return nil, err
+	}
+
+	inventory.VLANs, _, err = client.VLANs.List()
+	if err != nil {
+		return nil, err
+	}
+
+	return inventory, nil
+}
+
+// LoadInventory reads a JSON encoded Inventory.
+func LoadInventory(r io.Reader) (*Inventory, error) {
+	inventory := new(Inventory)
+	if err := json.NewDecoder(r).Decode(inventory); err != nil {
+		return nil, err
+	}
+
+	return inventory, nil
+}
+
+// Save writes the Inventory as JSON.
+func (i *Inventory) Save(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(i)
+}
+
+// Server returns the server with the given name or nil.
+func (i *Inventory) Server(name string) *CloudServerDetails {
+	for j := range i.Servers {
+		if i.Servers[j].Name == name {
+			return &i.Servers[j]
+		}
+	}
+	return nil
+}
+
+// ServerById returns the server with the given id or nil.
+func (i *Inventory) ServerById(serverId int) *CloudServerDetails {
+	for j := range i.Servers {
+		if i.Servers[j].ServerId == serverId {
+			return &i.Servers[j]
+		}
+	}
+	return nil
+}
+
+// VLAN returns the VLAN with the given name or nil.
+func (i *Inventory) VLAN(name string) *PurchasedVLAN {
+	for j := range i.VLANs {
+		if i.VLANs[j].Name == name {
+			return &i.VLANs[j]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package goarubacloud
+
+import (
+	"fmt"
+	"net/netip"
+	"strings"
+)
+
+// IPv6Network is the parsed IPv6 configuration of a public IP address.
+type IPv6Network struct {
+	Gateway netip.Addr
+	Prefix  netip.Prefix
+
+	// First and last address of the range assigned to the server
+	Start netip.Addr
+	End   netip.Addr
+}
+
+// Contains reports whether the address is inside the assigned range.
+func (n *IPv6Network) Contains(addr netip.Addr) bool {
+	return n.Start.Compare(addr) <= 0 && addr.Compare(n.End) <= 0
+}
+
+// IPv6 returns the parsed IPv6 configuration of the IP address.
+func (ip *IpAddress) IPv6() (*IPv6Network, error) {
+	return parseIPv6Network(ip.Value, ip.GatewayIPv6, ip.PrefixIPv6, ip.SubnetPrefixIPv6, ip.StartRangeIPv6, ip.EndRangeIPv6)
+}
+
+// IPv6 returns the parsed IPv6 configuration of the purchased IP.
+func (ip *PurchasedIP) IPv6() (*IPv6Network, error) {
+	return parseIPv6Network(ip.Value, ip.GatewayIPv6, ip.PrefixIPv6, ip.SubnetPrefixIPv6, ip.StartRangeIPv6, ip.EndRangeIPv6)
+}
+
+// IPv6 returns the parsed IPv6 configuration of the IP address of a Cloud Server SMART.
+func (ip *EasyCloudIPAddress) IPv6() (*IPv6Network, error) {
+	return parseIPv6Network(ip.Value, ip.GatewayIPv6, ip.PrefixIPv6, ip.SubnetPrefixIPv6, ip.StartRangeIPv6, ip.EndRangeIPv6)
+}
+
+func parseIPv6Network(value string, gateway string, prefixLength int, subnetPrefix string, start string, end string) (*IPv6Network, error) {
+	if start == "" || end == "" {
+		return nil, fmt.Errorf("IP %s doesn't have an IPv6 range", value)
+	}
+
+	network := new(IPv6Network)
+	var err error
+	if network.Start, err = parseIPv6Addr("StartRangeIPv6", start); err != nil {
+		return nil, err
+	}
+	if network.End, err = parseIPv6Addr("EndRangeIPv6", end); err != nil {
+		return nil, err
+	}
+	if network.End.Less(network.Start) {
+		return nil, fmt.Errorf("IPv6 range %s - %s of IP %s is empty", start, end, value)
+	}
+	if gateway != "" {
+		if network.Gateway, err = parseIPv6Addr("GatewayIPv6", gateway); err != nil {
+			return nil, err
+		}
+	}
+
+	switch {
+	case strings.Contains(subnetPrefix, "/"):
+		network.Prefix, err = netip.ParsePrefix(subnetPrefix)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid SubnetPrefixIPv6 '%s': %s", subnetPrefix, err)
+		}
+	case prefixLength != 0:
+		address := network.Start
+		if subnetPrefix != "" {
+			if address, err = parseIPv6Addr("SubnetPrefixIPv6", subnetPrefix); err != nil {
+				return nil, err
+			}
+		}
+		network.Prefix, err = address.Prefix(prefixLength)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid PrefixIPv6 %d: %s", prefixLength, err)
+		}
+	}
+
+	return network, nil
+}
+
+func parseIPv6Addr(field string, value string) (netip.Addr, error) {
+	addr, err := netip.ParseAddr(value)
+	if err != nil || !addr.Is6() {
+		return netip.Addr{}, fmt.Errorf("Invalid %s '%s': not an IPv6 address", field, value)
+	}
+	return addr, nil
+}
@@ -0,0 +1,188 @@
+package goarubacloud
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"math/big"
+	"strings"
+)
+
+// Administrator password policy of the Arubacloud templates
+const (
+	minPasswordLength         = 8
+	maxPasswordLength         = 20
+	passwordSpecialCharacters = "!#$%&()*+,-./:;<=>?@[]^_{|}~"
+	generatedPasswordLength   = 16
+)
+
+const (
+	passwordLowerCharacters = "abcdefghijklmnopqrstuvwxyz"
+	passwordUpperCharacters = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	passwordDigitCharacters = "0123456789"
+)
+
+// IsWindows reports whether servers created from the template run Windows.
+func (t *OSTemplate) IsWindows() bool {
+	return strings.Contains(strings.ToLower(t.Description+" "+t.Name), "windows")
+}
+
+// ValidateAdministratorPassword checks a password against the Arubacloud password policy:
+// 8 to 20 characters with at least one lower case letter, upper case letter, digit and
+// special character. Passwords for Windows templates must not contain "administrator",
+// passwords for Linux templates must not contain "root". Both are rejected if template is
+// nil. The password is never part of the returned error.
+func ValidateAdministratorPassword(password string, template *OSTemplate) error {
+	if password == "" {
+		return NewArgError("AdministratorPassword", "cannot be empty")
+	}
+
+	reasons := []string{}
+	if len(password) < minPasswordLength || len(password) > maxPasswordLength {
+		reasons = append(reasons, "it must be 8 to 20 characters long")
+	}
+
+	var lower, upper, digit, special, invalid bool
+	for _, c := range password {
+		switch {
+		case strings.ContainsRune(passwordLowerCharacters, c):
+			lower = true
+		case strings.ContainsRune(passwordUpperCharacters, c):
+			upper = true
+		case strings.ContainsRune(passwordDigitCharacters, c):
+			digit = true
+		case strings.ContainsRune(passwordSpecialCharacters, c):
+			special = true
+		default:
+			invalid = true
+		}
+	}
+	if !lower || !upper || !digit || !special {
+		reasons = append(reasons, "it must contain a lower case letter, an upper case letter, a digit and one of "+
+			passwordSpecialCharacters)
+	}
+	if invalid {
+		reasons = append(reasons, "it may only contain letters, digits and "+passwordSpecialCharacters)
+	}
+
+	lowerPassword := strings.ToLower(password)
+	if (template == nil || template.IsWindows()) && strings.Contains(lowerPassword, "administrator") {
+		reasons = append(reasons, "it must not contain 'administrator'")
+	}
+	if (template == nil || !template.IsWindows()) && strings.Contains(lowerPassword, "root") {
+		reasons = append(reasons, "it must not contain 'root'")
+	}
+
+	if len(reasons) > 0 {
+		return NewArgError("AdministratorPassword", strings.Join(reasons, ", "))
+	}
+	return nil
+}
+
+// GenerateAdministratorPassword returns a random password which complies with the password
+// policy of all templates. The password is not stored, keep it safe.
+func GenerateAdministratorPassword() (string, error) {
+	classes := []string{passwordLowerCharacters, passwordUpperCharacters, passwordDigitCharacters, passwordSpecialCharacters}
+	all := strings.Join(classes, "")
+
+	for {
+		password := make([]byte, generatedPasswordLength)
+		for i := range password {
+			characters := all
+			if i < len(classes) {
+				characters = classes[i]
+			}
+			c, err := randomCharacter(characters)
+			if err != nil {
+				return "", err
+			}
+			password[i] = c
+		}
+
+		// Move the characters of the required classes to random positions
+		for i := len(password) - 1; i > 0; i-- {
+			j, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+			if err != nil {
+				return "", err
+			}
+			password[i], password[j.Int64()] = password[j.Int64()], password[i]
+		}
+
+		if ValidateAdministratorPassword(string(password), nil) == nil {
+			return string(password), nil
+		}
+	}
+}
+
+func randomCharacter(characters string) (byte, error) {
+	i, err := rand.Int(rand.Reader, big.NewInt(int64(len(characters))))
+	if err != nil {
+		return 0, err
+	}
+	return characters[i.Int64()], nil
+}
+
+// validateCreatePassword validates the administrator password of a create request against
+// the template of the request, if known.
+func validateCreatePassword(requestCreator CloudServerCreator) error {
+	switch request := requestCreator.(type) {
+	case *cloudServerCreateRequestPro:
+		return ValidateAdministratorPassword(request.AdministratorPassword, request.template)
+	case *cloudServerCreateRequestSmart:
+		return ValidateAdministratorPassword(request.AdministratorPassword, request.template)
+	}
+	return nil
+}
+
+// Fields which are never written to the logs. SessionId holds the token of a session
+var redactedFields = []string{"Password", "AdministratorPassword", "TemplatePassword", "SessionId",
+	"AuthenticationToken"}
+
+const redactedValue = "[REDACTED]"
+
+// redactJSON returns a JSON request or response body with the values of the redactedFields
+// replaced. Bodies which are not JSON are returned unchanged.
+func redactJSON(data []byte) string {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return string(data)
+	}
+	redactValue(value)
+
+	redacted, err := json.Marshal(value)
+	if err != nil {
+		return string(data)
+	}
+	return string(redacted)
+}
+
+func redactValue(value interface{}) {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, field := range value {
+			if isRedactedField(key) {
+				if s, ok := field.(string); ok && s != "" {
+					value[key] = redactedValue
+				}
+				continue
+			}
+			redactValue(field)
+		}
+	case []interface{}:
+		for _, item := range value {
+			redactValue(item)
+		}
+	}
+}
+
+func isRedactedField(key string) bool {
+	for _, field := range redactedFields {
+		if strings.EqualFold(key, field) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,215 @@
+package goarubacloud
+
+import "fmt"
+
+// AccountLimits are the resource limits of the Arubacloud account. Zero means unlimited.
+type AccountLimits struct {
+	MaxServers      int
+	MaxPurchasedIPs int
+	MaxVLANs        int
+}
+
+type PreflightCheck int
+
+const (
+	CHECK_ACCOUNT_LIMIT PreflightCheck = 1 + iota
+	CHECK_TEMPLATE
+	CHECK_RESOURCE_BOUNDS
+	CHECK_HYPERVISOR
+	CHECK_NAME
+	CHECK_PUBLIC_IP
+	CHECK_VLAN
+	CHECK_PASSWORD
+)
+
+var preflight_checks = [...]string{
+	"account limit",
+	"template",
+	"resource bounds",
+	"hypervisor",
+	"name",
+	"public IP",
+	"VLAN",
+	"administrator password",
+}
+
+// String returns the name of the PreflightCheck.
+func (m PreflightCheck) String() string {
+	return preflight_checks[m-1]
+}
+
+// PreflightViolation is a single problem found by a preflight check.
+type PreflightViolation struct {
+	Check  PreflightCheck
+	Field  string
+	Reason string
+}
+
+// String returns a human readable description of the violation.
+func (v PreflightViolation) String() string {
+	return fmt.Sprintf("%s: %s %s", v.Check, v.Field, v.Reason)
+}
+
+type preflight struct {
+	violations []PreflightViolation
+}
+
+func (p *preflight) fail(check PreflightCheck, field string, reason string) {
+	p.violations = append(p.violations, PreflightViolation{Check: check, Field: field, Reason: reason})
+}
+
+func (p *preflight) limit(field string, current int, added int, max int) {
+	if max != 0 && current+added > max {
+		p.fail(CHECK_ACCOUNT_LIMIT, field, fmt.Sprintf("would be %d, the account limit is %d", current+added, max))
+	}
+}
+
+func (p *preflight) bounds(template *OSTemplate, resourceType ResourceType, field string, value int) {
+	bounds := template.Bounds(resourceType)
+	if bounds != nil && !bounds.Contains(value) {
+		p.fail(CHECK_RESOURCE_BOUNDS, field, fmt.Sprintf("is %d, template '%s' allows %d-%d",
+			value, template.Name, bounds.Min, bounds.Max))
+	}
+}
+
+// Preflight validates a create request against the account limits, the template and the
+// existing servers without making any mutating call. All violations are returned at once
+// as a *PreflightError.
+func (s *CloudServersServiceOp) Preflight(requestCreator CloudServerCreator) error {
+	if requestCreator == nil {
+		return NewArgError("requestCreator", "cannot be nil")
+	}
+
+	var templateId int
+	var smart bool
+	var configureIPv6 bool
+	var password string
+	var proRequest *cloudServerCreateRequestPro
+	switch request := requestCreator.(type) {
+	case *cloudServerCreateRequestPro:
+		templateId = request.OSTemplateId
+		configureIPv6 = request.ConfigureIPv6
+		password = request.AdministratorPassword
+		proRequest = request
+	case *cloudServerCreateRequestSmart:
+		templateId = request.OSTemplateId
+		configureIPv6 = request.ConfigureIPv6
+		password = request.AdministratorPassword
+		smart = true
+	default:
+		return NewArgError("requestCreator", "unsupported request type")
+	}
+
+	servers, _, err := s.List()
+	if err != nil {
+		return err
+	}
+	purchasedIPs, _, err := s.client.PurchasedIPs.List()
+	if err != nil {
+		return err
+	}
+	vlans, _, err := s.client.VLANs.List()
+	if err != nil {
+		return err
+	}
+	hypervisors, _, err := s.client.Hypervisors.GetHypervisors()
+	if err != nil {
+		return err
+	}
+
+	check := new(preflight)
+	limits := s.client.AccountLimits
+
+	addedIPs := 0
+	if smart {
+		addedIPs = 1
+	}
+	check.limit("servers", len(servers), 1, limits.MaxServers)
+	check.limit("purchased IPs", len(purchasedIPs), addedIPs, limits.MaxPurchasedIPs)
+	check.limit("VLANs", len(vlans), 0, limits.MaxVLANs)
+
+	for _, server := range servers {
+		if server.Name == requestCreator.GetServerName() {
+			check.fail(CHECK_NAME, "Name", fmt.Sprintf("'%s' is already used by server %d", server.Name, server.ServerId))
+		}
+	}
+
+	template, hypervisor := findOsTemplateById(hypervisors, templateId)
+	switch {
+	case template == nil:
+		check.fail(CHECK_TEMPLATE, "OSTemplateId", fmt.Sprintf("%d does not exist", templateId))
+	case !template.Enabled:
+		check.fail(CHECK_TEMPLATE, "OSTemplateId", fmt.Sprintf("%d ('%s') is not enabled", templateId, template.Name))
+	case configureIPv6 && !template.Ipv6Compatible:
+		check.fail(CHECK_TEMPLATE, "ConfigureIPv6", fmt.Sprintf("template %d ('%s') is not IPv6 compatible",
+			templateId, template.Name))
+	}
+
+	if err := ValidateAdministratorPassword(password, template); err != nil {
+		reason := err.Error()
+		if argErr, ok := err.(*ArgError); ok {
+			reason = argErr.reason
+		}
+		check.fail(CHECK_PASSWORD, "AdministratorPassword", reason)
+	}
+
+	compatible := proHypervisorTypes
+	if smart {
+		compatible = smartHypervisorTypes
+	}
+	if hypervisor != nil && !hypervisor.HypervisorType.in(compatible) {
+		check.fail(CHECK_HYPERVISOR, "OSTemplateId", fmt.Sprintf("%d belongs to hypervisor '%s'",
+			templateId, hypervisor.HypervisorType))
+	}
+
+	if proRequest != nil {
+		if template != nil {
+			check.bounds(template, RESOURCE_CPU, "CPUQuantity", proRequest.CPUQuantity)
+			check.bounds(template, RESOURCE_RAM, "RAMQuantity", proRequest.RAMQuantity)
+			for i, disk := range proRequest.VirtualDisks {
+				if disk.VirtualDiskType < 0 || disk.VirtualDiskType >= maxVirtualDisks {
+					continue
+				}
+				check.bounds(template, virtual_disk_resource_types[disk.VirtualDiskType],
+					fmt.Sprintf("VirtualDisks[%d]", i), disk.Size)
+			}
+		}
+
+		for _, adapter := range proRequest.NetworkAdaptersConfiguration {
+			for _, publicIp := range adapter.PublicIpAddresses {
+				check.purchasedIP(purchasedIPs, publicIp.PublicIpAddressResourceId)
+			}
+			if adapter.PrivateVLan != nil {
+				check.vlan(vlans, adapter.PrivateVLan.VLanResourceId)
+			}
+		}
+	}
+
+	if len(check.violations) > 0 {
+		return &PreflightError{Violations: check.violations}
+	}
+	return nil
+}
+
+func (p *preflight) purchasedIP(purchasedIPs []PurchasedIP, resourceId int) {
+	for _, ip := range purchasedIPs {
+		if ip.ResourceId != resourceId {
+			continue
+		}
+		if ip.ServerId != 0 {
+			p.fail(CHECK_PUBLIC_IP, "PublicIpAddressResourceId", fmt.Sprintf("%d (%s) is attached to server %d",
+				resourceId, ip.Value, ip.ServerId))
+		}
+		return
+	}
+	p.fail(CHECK_PUBLIC_IP, "PublicIpAddressResourceId", fmt.Sprintf("%d is not a purchased IP", resourceId))
+}
+
+func (p *preflight) vlan(vlans []PurchasedVLAN, resourceId int) {
+	for _, vlan := range vlans {
+		if vlan.ResourceId == resourceId {
+			return
+		}
+	}
+	p.fail(CHECK_VLAN, "VLanResourceId", fmt.Sprintf("%d is not a purchased VLAN", resourceId))
+}
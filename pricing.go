@@ -0,0 +1,247 @@
+package goarubacloud
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Hours used to turn hourly prices into monthly prices.
+const hoursPerMonth = 730
+
+// PriceTable holds the hourly prices of the Arubacloud resources per datacenter.
+type PriceTable struct {
+	Currency string
+	Regions  []RegionPrices
+}
+
+// RegionPrices holds the hourly prices of a single datacenter.
+type RegionPrices struct {
+	Datacenter  DataCenterRegion
+	PublicIP    float64
+	VLAN        float64
+	Hypervisors []HypervisorPrices
+}
+
+// HypervisorPrices holds the hourly prices of the servers of a single hypervisor type.
+type HypervisorPrices struct {
+	HypervisorType HypervisorType
+
+	// Price per CPU, per GB of RAM and per GB of disk
+	CPU    float64
+	RAM    float64
+	DiskGB float64
+
+	SmartPackages []SmartPackagePrice
+}
+
+// SmartPackagePrice is the hourly price of a Cloud Server SMART size.
+type SmartPackagePrice struct {
+	Size  CloudServerSmartSize
+	Price float64
+}
+
+// CostItem is a single line of a CostEstimate.
+type CostItem struct {
+	Description string
+	Quantity    int
+
+	// Hourly price of all units
+	Hourly float64
+}
+
+// CostEstimate is the estimated cost of a set of resources.
+type CostEstimate struct {
+	Currency string
+	Items    []CostItem
+	Hourly   float64
+}
+
+// Monthly returns the estimated cost per month.
+func (e *CostEstimate) Monthly() float64 {
+	return e.Hourly * hoursPerMonth
+}
+
+func (e *CostEstimate) add(description string, quantity int, unitPrice float64) {
+	if quantity == 0 {
+		return
+	}
+	hourly := float64(quantity) * unitPrice
+	e.Items = append(e.Items, CostItem{Description: description, Quantity: quantity, Hourly: hourly})
+	e.Hourly += hourly
+}
+
+func (e *CostEstimate) merge(other *CostEstimate) {
+	e.Items = append(e.Items, other.Items...)
+	e.Hourly += other.Hourly
+}
+
+// LoadPriceTable reads a JSON encoded PriceTable.
+func LoadPriceTable(r io.Reader) (*PriceTable, error) {
+	table := new(PriceTable)
+	if err := json.NewDecoder(r).Decode(table); err != nil {
+		return nil, err
+	}
+
+	return table, nil
+}
+
+// Region returns the prices of the datacenter.
+func (t *PriceTable) Region(datacenter DataCenterRegion) (*RegionPrices, error) {
+	for i := range t.Regions {
+		if t.Regions[i].Datacenter == datacenter {
+			return &t.Regions[i], nil
+		}
+	}
+	return nil, fmt.Errorf("No prices for datacenter %s", datacenter)
+}
+
+// Hypervisor returns the prices of the hypervisor type in the datacenter.
+func (t *PriceTable) Hypervisor(datacenter DataCenterRegion, hypervisorType HypervisorType) (*HypervisorPrices, error) {
+	region, err := t.Region(datacenter)
+	if err != nil {
+		return nil, err
+	}
+	for i := range region.Hypervisors {
+		if region.Hypervisors[i].HypervisorType == hypervisorType {
+			return &region.Hypervisors[i], nil
+		}
+	}
+	return nil, fmt.Errorf("No prices for hypervisor '%s' in datacenter %s", hypervisorType, datacenter)
+}
+
+func (p *HypervisorPrices) smartPackage(size CloudServerSmartSize) (float64, error) {
+	for _, smartPackage := range p.SmartPackages {
+		if smartPackage.Size == size {
+			return smartPackage.Price, nil
+		}
+	}
+	return 0, fmt.Errorf("No price for smart package %s", size)
+}
+
+// EstimateCreate estimates the cost of a pending create request. Smart requests are
+// always priced as VMWare_Cloud_Smart, PRO requests with the given hypervisor type.
+func (t *PriceTable) EstimateCreate(datacenter DataCenterRegion, hypervisorType HypervisorType,
+	requestCreator CloudServerCreator) (*CostEstimate, error) {
+	if requestCreator == nil {
+		return nil, NewArgError("requestCreator", "cannot be nil")
+	}
+
+	estimate := &CostEstimate{Currency: t.Currency}
+	switch request := requestCreator.GetRequest().(type) {
+	case *cloudServerCreateRequestSmart:
+		prices, err := t.Hypervisor(datacenter, VMWare_Cloud_Smart)
+		if err != nil {
+			return nil, err
+		}
+		price, err := prices.smartPackage(request.CloudServerSmartType)
+		if err != nil {
+			return nil, err
+		}
+		estimate.add(fmt.Sprintf("Smart package %s", request.CloudServerSmartType), 1, price)
+	case *cloudServerCreateRequestPro:
+		region, err := t.Region(datacenter)
+		if err != nil {
+			return nil, err
+		}
+		prices, err := t.Hypervisor(datacenter, hypervisorType)
+		if err != nil {
+			return nil, err
+		}
+		diskSize := 0
+		for _, disk := range request.VirtualDisks {
+			diskSize += disk.Size
+		}
+		publicIPs := 0
+		for _, adapter := range request.NetworkAdaptersConfiguration {
+			publicIPs += len(adapter.PublicIpAddresses)
+		}
+		estimate.add("CPU", request.CPUQuantity, prices.CPU)
+		estimate.add("RAM GB", request.RAMQuantity, prices.RAM)
+		estimate.add("Disk GB", diskSize, prices.DiskGB)
+		estimate.add("Public IP", publicIPs, region.PublicIP)
+	default:
+		return nil, NewArgError("requestCreator", "unsupported request type")
+	}
+
+	return estimate, nil
+}
+
+// EstimateResize estimates the difference in cost between the current size of a PRO
+// server and the given CPU and RAM quantities. A negative cost means a saving.
+func (t *PriceTable) EstimateResize(server *CloudServerDetails, cpuQuantity int, ramQuantity int) (*CostEstimate, error) {
+	if server == nil {
+		return nil, NewArgError("server", "cannot be nil")
+	}
+	if server.EasyCloudPackageID != 0 {
+		return nil, NewArgError("server", "smart servers can not be resized")
+	}
+
+	prices, err := t.Hypervisor(server.DatacenterId, server.HypervisorType)
+	if err != nil {
+		return nil, err
+	}
+
+	estimate := &CostEstimate{Currency: t.Currency}
+	estimate.add("CPU", cpuQuantity-server.CPUQuantity.Quantity, prices.CPU)
+	estimate.add("RAM GB", ramQuantity-server.RAMQuantity.Quantity, prices.RAM)
+	return estimate, nil
+}
+
+// EstimateServer estimates the cost of an existing server, without its public IPs.
+func (t *PriceTable) EstimateServer(server *CloudServerDetails) (*CostEstimate, error) {
+	if server == nil {
+		return nil, NewArgError("server", "cannot be nil")
+	}
+
+	prices, err := t.Hypervisor(server.DatacenterId, server.HypervisorType)
+	if err != nil {
+		return nil, err
+	}
+
+	estimate := &CostEstimate{Currency: t.Currency}
+	if server.EasyCloudPackageID != 0 {
+		size := CloudServerSmartSize(server.EasyCloudPackageID)
+		price, err := prices.smartPackage(size)
+		if err != nil {
+			return nil, err
+		}
+		estimate.add(fmt.Sprintf("%s: smart package %s", server.Name, size), 1, price)
+		return estimate, nil
+	}
+
+	diskSize := 0
+	for _, disk := range server.VirtualDisks {
+		diskSize += disk.Size
+	}
+	estimate.add(fmt.Sprintf("%s: CPU", server.Name), server.CPUQuantity.Quantity, prices.CPU)
+	estimate.add(fmt.Sprintf("%s: RAM GB", server.Name), server.RAMQuantity.Quantity, prices.RAM)
+	estimate.add(fmt.Sprintf("%s: Disk GB", server.Name), diskSize, prices.DiskGB)
+	return estimate, nil
+}
+
+// EstimateVirtualDatacenter estimates the cost of all servers, public IPs and VLANs of
+// the datacenter.
+func (t *PriceTable) EstimateVirtualDatacenter(datacenter *VirtualDatacenter) (*CostEstimate, error) {
+	if datacenter == nil {
+		return nil, NewArgError("datacenter", "cannot be nil")
+	}
+
+	region, err := t.Region(datacenter.DatacenterRegion)
+	if err != nil {
+		return nil, err
+	}
+
+	estimate := &CostEstimate{Currency: t.Currency}
+	for i := range datacenter.Servers {
+		serverEstimate, err := t.EstimateServer(&datacenter.Servers[i])
+		if err != nil {
+			return nil, err
+		}
+		estimate.merge(serverEstimate)
+	}
+	estimate.add("Public IP", len(datacenter.PublicIpAddresses), region.PublicIP)
+	estimate.add("VLAN", len(datacenter.VLans), region.VLAN)
+
+	return estimate, nil
+}
@@ -79,6 +79,9 @@ func (s *PurchasedIPsServiceOp) Purchase() (*PurchasedIP, *Response, error) {
 	if err != nil {
 		return nil, resp, err
 	}
+	if resp.DryRun != nil {
+		return &PurchasedIP{ResourceId: DryRunResourceId}, resp, nil
+	}
 
 	return root.PurchasedIP, resp, nil
 }
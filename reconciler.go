@@ -0,0 +1,515 @@
+package goarubacloud
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+)
+
+// DesiredState is a declarative description of the Cloud Servers, purchased IPs and
+// VLANs that should exist in a datacenter.
+type DesiredState struct {
+	Servers []DesiredServer
+	VLANs   []DesiredVLAN
+
+	// Number of purchased IPs that should be kept unattached
+	FreeIPs int
+}
+
+// DesiredServer describes a single Cloud Server of a DesiredState.
+type DesiredServer struct {
+	Name                  string
+	Smart                 bool
+	SmartSize             CloudServerSmartSize
+	OSTemplateId          int
+	AdministratorPassword string
+	CPUQuantity           int
+	RAMQuantity           int
+	Disks                 []int
+	PublicIPs             int
+	VLANs                 []string
+	Note                  string
+}
+
+// DesiredVLAN describes a single VLAN of a DesiredState.
+type DesiredVLAN struct {
+	Name string
+}
+
+// LoadDesiredState reads a JSON encoded DesiredState.
+func LoadDesiredState(r io.Reader) (*DesiredState, error) {
+	state := new(DesiredState)
+	if err := json.NewDecoder(r).Decode(state); err != nil {
+		return nil, err
+	}
+
+	return state, nil
+}
+
+type PlanAction int
+
+const (
+	PLAN_CREATE PlanAction = 1 + iota
+	PLAN_RESIZE
+	PLAN_ATTACH
+	PLAN_DETACH
+	PLAN_DELETE
+)
+
+var plan_actions = [...]string{
+	"create",
+	"resize",
+	"attach",
+	"detach",
+	"delete",
+}
+
+// String returns the name of the PlanAction.
+func (m PlanAction) String() string {
+	return plan_actions[m-1]
+}
+
+type PlanResourceType int
+
+const (
+	PLAN_SERVER PlanResourceType = 1 + iota
+	PLAN_PURCHASED_IP
+	PLAN_VLAN
+)
+
+var plan_resource_types = [...]string{
+	"server",
+	"purchased IP",
+	"VLAN",
+}
+
+// String returns the name of the PlanResourceType.
+func (m PlanResourceType) String() string {
+	return plan_resource_types[m-1]
+}
+
+// PlanStep is a single change computed by the Reconciler.
+type PlanStep struct {
+	Action       PlanAction
+	ResourceType PlanResourceType
+
+	// Name of the server or VLAN, or the address of the purchased IP
+	Name string
+
+	// Id of the existing resource. 0 for resources created by the plan
+	ResourceId int
+
+	// Server and VLAN names for attach and detach steps. For purchased IP steps the
+	// name of the server created with the IP
+	ServerName string
+	VLANName   string
+
+	// Network adapter used by detach steps
+	NetworkAdapterId int
+
+	// Desired server for create and resize steps
+	Server *DesiredServer
+}
+
+// String returns a human readable description of the step.
+func (s PlanStep) String() string {
+	switch s.Action {
+	case PLAN_ATTACH:
+		return fmt.Sprintf("attach server '%s' to VLAN '%s'", s.ServerName, s.VLANName)
+	case PLAN_DETACH:
+		return fmt.Sprintf("detach server '%s' from VLAN '%s'", s.ServerName, s.VLANName)
+	case PLAN_RESIZE:
+		return fmt.Sprintf("resize server '%s' to %d CPU, %d GB RAM",
+			s.Name, s.Server.CPUQuantity, s.Server.RAMQuantity)
+	case PLAN_CREATE:
+		if s.ResourceType == PLAN_PURCHASED_IP && s.ServerName != "" {
+			return fmt.Sprintf("create purchased IP for server '%s'", s.ServerName)
+		}
+	}
+	return fmt.Sprintf("%s %s '%s'", s.Action, s.ResourceType, s.Name)
+}
+
+// Plan is the ordered list of changes needed to reach a DesiredState.
+type Plan struct {
+	Steps []PlanStep
+
+	// Differences that the plan can not resolve
+	Warnings []string
+}
+
+// PlanStepCallback is called after every step applied by the Reconciler.
+type PlanStepCallback func(step *PlanStep, index int, total int, err error)
+
+// Reconciler computes and applies the changes needed to bring the datacenter to a
+// DesiredState.
+type Reconciler struct {
+	client *Client
+
+	// Delete servers, VLANs and purchased IPs which are not part of the desired state
+	AllowDeletes bool
+
+	// Optional function called after every applied step
+	onStepCompleted PlanStepCallback
+}
+
+// NewReconciler returns a new Reconciler.
+func NewReconciler(client *Client) *Reconciler {
+	return &Reconciler{client: client}
+}
+
+// OnStepCompleted sets the plan step completion callback
+func (r *Reconciler) OnStepCompleted(cb PlanStepCallback) {
+	r.onStepCompleted = cb
+}
+
+// Plan reads the current state of the datacenter and computes the steps needed to
+// reach the desired state. Delete and detach steps are only planned if AllowDeletes is set.
+func (r *Reconciler) Plan(desired *DesiredState) (*Plan, error) {
+	if desired == nil {
+		return nil, NewArgError("desired", "cannot be nil")
+	}
+
+	current, err := TakeInventory(r.client)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := new(Plan)
+	desiredServers := map[string]bool{}
+	desiredVLANs := map[string]bool{}
+
+	for _, vlan := range desired.VLANs {
+		desiredVLANs[vlan.Name] = true
+		if current.VLAN(vlan.Name) == nil {
+			plan.Steps = append(plan.Steps, PlanStep{Action: PLAN_CREATE, ResourceType: PLAN_VLAN, Name: vlan.Name})
+		}
+	}
+
+	freeIPs := 0
+	for _, ip := range current.PurchasedIPs {
+		if ip.ServerId == 0 {
+			freeIPs++
+		}
+	}
+	for i := freeIPs; i < desired.FreeIPs; i++ {
+		plan.Steps = append(plan.Steps, PlanStep{Action: PLAN_CREATE, ResourceType: PLAN_PURCHASED_IP})
+	}
+
+	var attaches, detaches []PlanStep
+	for i := range desired.Servers {
+		server := &desired.Servers[i]
+		desiredServers[server.Name] = true
+
+		for _, vlanName := range server.VLANs {
+			if !desiredVLANs[vlanName] {
+				return nil, NewArgError("VLANs", fmt.Sprintf("VLAN '%s' of server '%s' is not declared", vlanName, server.Name))
+			}
+		}
+
+		details := current.Server(server.Name)
+		if details == nil {
+			if !server.Smart {
+				for j := 0; j < server.PublicIPs; j++ {
+					plan.Steps = append(plan.Steps, PlanStep{Action: PLAN_CREATE, ResourceType: PLAN_PURCHASED_IP,
+						ServerName: server.Name})
+				}
+			}
+			plan.Steps = append(plan.Steps, PlanStep{Action: PLAN_CREATE, ResourceType: PLAN_SERVER,
+				Name: server.Name, Server: server})
+			for _, vlanName := range server.VLANs {
+				attaches = append(attaches, PlanStep{Action: PLAN_ATTACH, ResourceType: PLAN_VLAN,
+					ServerName: server.Name, VLANName: vlanName})
+			}
+			continue
+		}
+
+		if !server.Smart && ((server.CPUQuantity != 0 && server.CPUQuantity != details.CPUQuantity.Quantity) ||
+			(server.RAMQuantity != 0 && server.RAMQuantity != details.RAMQuantity.Quantity)) {
+			plan.Steps = append(plan.Steps, PlanStep{Action: PLAN_RESIZE, ResourceType: PLAN_SERVER,
+				Name: server.Name, ResourceId: details.ServerId, Server: server})
+		}
+
+		if len(server.Disks) != 0 && len(server.Disks) != len(details.VirtualDisks) {
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf("server '%s' has %d disks, %d declared",
+				server.Name, len(details.VirtualDisks), len(server.Disks)))
+		}
+
+		publicIPs := 0
+		for _, adapter := range details.NetworkAdapters {
+			publicIPs += len(adapter.IPAddresses)
+		}
+		if server.PublicIPs != 0 && server.PublicIPs != publicIPs {
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf("server '%s' has %d public IPs, %d declared",
+				server.Name, publicIPs, server.PublicIPs))
+		}
+
+		joined := map[string]bool{}
+		for _, adapter := range details.NetworkAdapters {
+			if adapter.VLan.ResourceId != 0 {
+				joined[adapter.VLan.Name] = true
+			}
+		}
+		wanted := map[string]bool{}
+		for _, vlanName := range server.VLANs {
+			wanted[vlanName] = true
+			if !joined[vlanName] {
+				attaches = append(attaches, PlanStep{Action: PLAN_ATTACH, ResourceType: PLAN_VLAN,
+					ServerName: server.Name, ResourceId: details.ServerId, VLANName: vlanName})
+			}
+		}
+		for _, adapter := range details.NetworkAdapters {
+			if adapter.VLan.ResourceId == 0 || wanted[adapter.VLan.Name] {
+				continue
+			}
+			step := PlanStep{Action: PLAN_DETACH, ResourceType: PLAN_VLAN, ServerName: server.Name,
+				ResourceId: details.ServerId, VLANName: adapter.VLan.Name, NetworkAdapterId: adapter.Id}
+			if r.AllowDeletes {
+				detaches = append(detaches, step)
+			} else {
+				plan.Warnings = append(plan.Warnings, fmt.Sprintf("not planned, deletes are disabled: %s", step))
+			}
+		}
+	}
+
+	plan.Steps = append(plan.Steps, attaches...)
+	plan.Steps = append(plan.Steps, detaches...)
+
+	var deletes []PlanStep
+	for _, server := range current.Servers {
+		if !desiredServers[server.Name] {
+			deletes = append(deletes, PlanStep{Action: PLAN_DELETE, ResourceType: PLAN_SERVER,
+				Name: server.Name, ResourceId: server.ServerId})
+		}
+	}
+	for _, vlan := range current.VLANs {
+		if !desiredVLANs[vlan.Name] {
+			deletes = append(deletes, PlanStep{Action: PLAN_DELETE, ResourceType: PLAN_VLAN,
+				Name: vlan.Name, ResourceId: vlan.ResourceId})
+		}
+	}
+	excessIPs := freeIPs - desired.FreeIPs
+	for _, ip := range current.PurchasedIPs {
+		if excessIPs <= 0 {
+			break
+		}
+		if ip.ServerId == 0 {
+			deletes = append(deletes, PlanStep{Action: PLAN_DELETE, ResourceType: PLAN_PURCHASED_IP,
+				Name: ip.Value, ResourceId: ip.ResourceId})
+			excessIPs--
+		}
+	}
+
+	for _, step := range deletes {
+		if r.AllowDeletes {
+			plan.Steps = append(plan.Steps, step)
+		} else {
+			plan.Warnings = append(plan.Warnings, fmt.Sprintf("not planned, deletes are disabled: %s", step))
+		}
+	}
+
+	return plan, nil
+}
+
+// Apply executes the steps of a plan in order and stops at the first failing step. It
+// fails in dry-run mode, as later steps depend on the resources created by earlier ones.
+func (r *Reconciler) Apply(plan *Plan) error {
+	if plan == nil {
+		return NewArgError("plan", "cannot be nil")
+	}
+	if r.client.DryRun {
+		return fmt.Errorf("Apply is not supported in dry-run mode, review the plan instead")
+	}
+
+	serverIds := map[string]int{}
+	vlanIds := map[string]int{}
+	serverIPs := map[string][]int{}
+
+	total := len(plan.Steps)
+	for i := range plan.Steps {
+		step := &plan.Steps[i]
+		log.Printf("[INFO] Step %d/%d: %s\n", i+1, total, step)
+
+		err := r.applyStep(step, serverIds, vlanIds, serverIPs)
+		if r.onStepCompleted != nil {
+			r.onStepCompleted(step, i, total, err)
+		}
+		if err != nil {
+			return fmt.Errorf("step %d/%d (%s) failed: %s", i+1, total, step, err)
+		}
+	}
+
+	return nil
+}
+
+func (r *Reconciler) applyStep(step *PlanStep, serverIds map[string]int, vlanIds map[string]int,
+	serverIPs map[string][]int) error {
+	if (step.Action == PLAN_DELETE || step.Action == PLAN_DETACH) && !r.AllowDeletes {
+		return fmt.Errorf("deletes are disabled")
+	}
+
+	switch step.ResourceType {
+	case PLAN_SERVER:
+		switch step.Action {
+		case PLAN_CREATE:
+			server, err := r.createServer(step.Server, serverIPs[step.Name])
+			if err != nil {
+				r.releaseIPs(serverIPs[step.Name])
+				return err
+			}
+			serverIds[step.Name] = server.ServerId
+			step.ResourceId = server.ServerId
+			return nil
+		case PLAN_RESIZE:
+			_, err := r.client.CloudServerActions.Resize(&ServerResizeRequest{ServerId: step.ResourceId,
+				CPUQuantity: step.Server.CPUQuantity, RAMQuantity: step.Server.RAMQuantity})
+			return err
+		case PLAN_DELETE:
+			_, err := r.client.CloudServers.Delete(step.ResourceId)
+			return err
+		}
+	case PLAN_PURCHASED_IP:
+		switch step.Action {
+		case PLAN_CREATE:
+			ip, _, err := r.client.PurchasedIPs.Purchase()
+			if err != nil {
+				return err
+			}
+			step.Name = ip.Value
+			step.ResourceId = ip.ResourceId
+			if step.ServerName != "" {
+				serverIPs[step.ServerName] = append(serverIPs[step.ServerName], ip.ResourceId)
+			}
+			return nil
+		case PLAN_DELETE:
+			_, err := r.client.PurchasedIPs.Delete(step.ResourceId)
+			return err
+		}
+	case PLAN_VLAN:
+		switch step.Action {
+		case PLAN_CREATE:
+			vlan, _, err := r.client.VLANs.Purchase(step.Name)
+			if err != nil {
+				return err
+			}
+			vlanIds[step.Name] = vlan.ResourceId
+			step.ResourceId = vlan.ResourceId
+			return nil
+		case PLAN_ATTACH:
+			return r.attachVLAN(step, serverIds, vlanIds)
+		case PLAN_DETACH:
+			vlanId, err := r.vlanId(step.VLANName, vlanIds)
+			if err != nil {
+				return err
+			}
+			_, err = r.client.VLANs.Detach(step.NetworkAdapterId, vlanId)
+			return err
+		case PLAN_DELETE:
+			_, err := r.client.VLANs.Delete(step.ResourceId)
+			return err
+		}
+	}
+
+	return fmt.Errorf("unsupported step")
+}
+
+// createServer creates a server with the public IPs purchased by the preceding steps of the plan.
+func (r *Reconciler) createServer(desired *DesiredServer, ipIds []int) (*CloudServer, error) {
+	var creator CloudServerCreator
+	if desired.Smart {
+		creator = NewCloudServerSmartCreateRequest(desired.SmartSize, desired.Name,
+			desired.AdministratorPassword, desired.OSTemplateId)
+	} else {
+		proCreator := NewCloudServerProCreateRequest(desired.Name, desired.AdministratorPassword, desired.OSTemplateId)
+		if desired.CPUQuantity != 0 {
+			if err := proCreator.SetCPUQuantity(desired.CPUQuantity); err != nil {
+				return nil, err
+			}
+		}
+		if desired.RAMQuantity != 0 {
+			if err := proCreator.SetRAMQuantity(desired.RAMQuantity); err != nil {
+				return nil, err
+			}
+		}
+		for _, size := range desired.Disks {
+			if err := proCreator.AddVirtualDisk(size); err != nil {
+				return nil, err
+			}
+		}
+		for _, ipId := range ipIds {
+			if err := proCreator.AddPublicIp(ipId); err != nil {
+				return nil, err
+			}
+		}
+		creator = proCreator
+	}
+
+	if err := creator.SetNote(desired.Note); err != nil {
+		return nil, err
+	}
+
+	server, _, err := r.client.CloudServers.Create(creator)
+	return server, err
+}
+
+// releaseIPs deletes the IPs purchased for a server which could not be created.
+func (r *Reconciler) releaseIPs(ipIds []int) {
+	for _, ipId := range ipIds {
+		log.Printf("[INFO] Releasing purchased IP %d\n", ipId)
+		if _, err := r.client.PurchasedIPs.Delete(ipId); err != nil {
+			log.Printf("[WARN] Unable to release purchased IP %d: %s\n", ipId, err)
+		}
+	}
+}
+
+func (r *Reconciler) attachVLAN(step *PlanStep, serverIds map[string]int, vlanIds map[string]int) error {
+	serverId := step.ResourceId
+	if serverId == 0 {
+		serverId = serverIds[step.ServerName]
+	}
+	if serverId == 0 {
+		return fmt.Errorf("server '%s' not found", step.ServerName)
+	}
+
+	vlanId, err := r.vlanId(step.VLANName, vlanIds)
+	if err != nil {
+		return err
+	}
+
+	err = WaitForServerCreationDone(r.client, serverId)
+	if err != nil {
+		return err
+	}
+
+	details, _, err := r.client.CloudServers.Get(serverId)
+	if err != nil {
+		return err
+	}
+
+	adapterId, err := details.FreePrivateNetworkAdapter()
+	if err != nil {
+		return err
+	}
+
+	_, _, err = r.client.VLANs.Attach(NewPurchasedVLanAttachRequest(adapterId, vlanId))
+	return err
+}
+
+func (r *Reconciler) vlanId(name string, vlanIds map[string]int) (int, error) {
+	if id, ok := vlanIds[name]; ok {
+		return id, nil
+	}
+
+	vlans, _, err := r.client.VLANs.List()
+	if err != nil {
+		return 0, err
+	}
+	for _, vlan := range vlans {
+		if vlan.Name == name {
+			vlanIds[name] = vlan.ResourceId
+			return vlan.ResourceId, nil
+		}
+	}
+
+	return 0, fmt.Errorf("VLAN '%s' not found", name)
+}
@@ -0,0 +1,207 @@
+package goarubacloud
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+const smartServerRenewPath = "SetEnqueueSmartServerRenewal"
+const smartServerAutoRenewPath = "SetSmartServerAutoRenewal"
+
+// RenewalsService is an interface for tracking and renewing Cloud Server SMART
+type RenewalsService interface {
+	ListExpiring(days int) ([]SmartServerRenewal, *Response, error)
+	Renew(serverId int) (*Response, error)
+	SetAutoRenew(serverId int, enabled bool) (*Response, error)
+	Report(days int) (*RenewalReport, error)
+}
+
+// RenewalsServiceOp handles communication with the renewal related methods of the
+// Arubacloud API.
+type RenewalsServiceOp struct {
+	client *Client
+}
+
+var _ RenewalsService = &RenewalsServiceOp{}
+
+// SmartServerRenewal is the renewal date of a Cloud Server SMART.
+type SmartServerRenewal struct {
+	ServerId  int
+	Name      string
+	RenewDate time.Time
+}
+
+// DaysLeft returns the number of full days until the renewal date. It is negative for
+// servers which are already expired.
+func (r *SmartServerRenewal) DaysLeft(now time.Time) int {
+	return int(r.RenewDate.Sub(now).Hours() / 24)
+}
+
+// RenewalReport lists the Cloud Servers SMART which are expired or expiring soon.
+type RenewalReport struct {
+	GeneratedAt time.Time
+	Days        int
+	Expired     []SmartServerRenewal
+	Expiring    []SmartServerRenewal
+
+	// Servers without a valid renew date. Their RenewDate is zero
+	Unknown []SmartServerRenewal
+}
+
+// NeedsAttention reports whether any server is expired, expiring or has an unknown renew date.
+func (r *RenewalReport) NeedsAttention() bool {
+	return len(r.Expired) > 0 || len(r.Expiring) > 0 || len(r.Unknown) > 0
+}
+
+// String returns the report as human readable text.
+func (r *RenewalReport) String() string {
+	var buffer bytes.Buffer
+	fmt.Fprintf(&buffer, "Smart server renewals on %s, expiring within %d days\n",
+		r.GeneratedAt.Format("2006-01-02"), r.Days)
+	if !r.NeedsAttention() {
+		buffer.WriteString("No servers expiring\n")
+	}
+	for _, renewal := range r.Expired {
+		fmt.Fprintf(&buffer, "EXPIRED  %s (%d) on %s\n", renewal.Name, renewal.ServerId,
+			renewal.RenewDate.Format("2006-01-02"))
+	}
+	for _, renewal := range r.Expiring {
+		fmt.Fprintf(&buffer, "EXPIRING %s (%d) on %s, %d days left\n", renewal.Name, renewal.ServerId,
+			renewal.RenewDate.Format("2006-01-02"), renewal.DaysLeft(r.GeneratedAt))
+	}
+	for _, renewal := range r.Unknown {
+		fmt.Fprintf(&buffer, "UNKNOWN  %s (%d), no valid renew date\n", renewal.Name, renewal.ServerId)
+	}
+	return buffer.String()
+}
+
+var apiDatePattern = regexp.MustCompile(`^/Date\((-?\d+)([+-]\d{4})?\)/$`)
+
+// parseAPIDate parses dates like "/Date(1483138800000+0100)/" used by the Arubacloud API. RFC
+// 3339 dates are accepted as well.
+func parseAPIDate(value string) (time.Time, error) {
+	if match := apiDatePattern.FindStringSubmatch(value); match != nil {
+		milliseconds, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.Unix(0, milliseconds*int64(time.Millisecond)), nil
+	}
+
+	date, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Unable to parse date '%s'", value)
+	}
+	return date, nil
+}
+
+// RenewDate returns RenewDateSmart of a Cloud Server SMART as time.
+func (s *CloudServerDetails) RenewDate() (time.Time, error) {
+	if s.RenewDateSmart == "" {
+		return time.Time{}, fmt.Errorf("Server %d doesn't have a renew date", s.ServerId)
+	}
+	return parseAPIDate(s.RenewDateSmart)
+}
+
+// ListExpiring lists the Cloud Servers SMART which expire within the given number of days,
+// including those which are already expired. Servers without a valid renew date are skipped.
+func (s *RenewalsServiceOp) ListExpiring(days int) ([]SmartServerRenewal, *Response, error) {
+	renewals, _, resp, err := s.listExpiring(days)
+	return renewals, resp, err
+}
+
+// listExpiring returns the expiring servers and the servers without a valid renew date.
+func (s *RenewalsServiceOp) listExpiring(days int) ([]SmartServerRenewal, []SmartServerRenewal, *Response, error) {
+	servers, resp, err := s.client.CloudServers.ListWithOptions(&ListOptions{
+		Filters: []CloudServerFilter{ServerHypervisorIn(VMWare_Cloud_Smart)},
+	})
+	if err != nil {
+		return nil, nil, resp, err
+	}
+
+	deadline := time.Now().AddDate(0, 0, days)
+	renewals := []SmartServerRenewal{}
+	var unknown []SmartServerRenewal
+	for _, server := range servers {
+		serverDetails, resp, err := s.client.CloudServers.Get(server.ServerId)
+		if err != nil {
+			return nil, nil, resp, err
+		}
+
+		renewal := SmartServerRenewal{ServerId: server.ServerId, Name: server.Name}
+		renewal.RenewDate, err = serverDetails.RenewDate()
+		if err != nil {
+			log.Printf("[WARN] Skipping server %d: %s\n", server.ServerId, err)
+			renewal.RenewDate = time.Time{}
+			unknown = append(unknown, renewal)
+			continue
+		}
+
+		if renewal.RenewDate.Before(deadline) {
+			renewals = append(renewals, renewal)
+		}
+	}
+
+	return renewals, unknown, resp, nil
+}
+
+// Renew a Cloud Server SMART
+func (s *RenewalsServiceOp) Renew(serverId int) (*Response, error) {
+	req, err := s.client.NewRequest(smartServerRenewPath, ServerIdCreate{ServerId: serverId})
+
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.client.Do(req, nil)
+	if err != nil {
+		return resp, err
+	}
+
+	return resp, err
+}
+
+// SetAutoRenew enables or disables the automatic renewal of a Cloud Server SMART
+func (s *RenewalsServiceOp) SetAutoRenew(serverId int, enabled bool) (*Response, error) {
+	body := struct {
+		ServerId    int
+		AutoRenewal bool
+	}{serverId, enabled}
+
+	req, err := s.client.NewRequest(smartServerAutoRenewPath, body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.client.Do(req, nil)
+	if err != nil {
+		return resp, err
+	}
+
+	return resp, err
+}
+
+// Report builds a RenewalReport of the Cloud Servers SMART expiring within the given number
+// of days, for use in scheduled jobs.
+func (s *RenewalsServiceOp) Report(days int) (*RenewalReport, error) {
+	renewals, unknown, _, err := s.listExpiring(days)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &RenewalReport{GeneratedAt: time.Now(), Days: days, Unknown: unknown}
+	for _, renewal := range renewals {
+		if renewal.RenewDate.Before(report.GeneratedAt) {
+			report.Expired = append(report.Expired, renewal)
+		} else {
+			report.Expiring = append(report.Expiring, renewal)
+		}
+	}
+
+	return report, nil
+}
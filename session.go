@@ -0,0 +1,206 @@
+package goarubacloud
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const userAuthenticationTokenPath = "GetUserAuthenticationToken"
+
+const (
+	// Tokens are renewed this long before they expire
+	sessionRenewalMargin = time.Minute
+
+	// Lifetime of tokens returned without an expiration date
+	defaultSessionLifetime = 30 * time.Minute
+)
+
+type session struct {
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+type authenticationToken struct {
+	AuthenticationToken string
+	ExpirationDate      string
+}
+
+type authenticationTokenRoot struct {
+	AuthenticationToken *authenticationToken `json:"Value"`
+}
+
+// EnableSessions makes the client send the password only once, to get an authentication
+// token with GetUserAuthenticationToken. The token is sent as SessionId instead of the
+// password with all other requests and renewed before it expires. A call rejected because
+// of its token is retried once with a new token.
+func (c *Client) EnableSessions() {
+	c.session = &session{}
+}
+
+// DisableSessions sends the password with every request again and forgets the token.
+func (c *Client) DisableSessions() {
+	c.session = nil
+}
+
+// credentials returns the authentication fields of a request body.
+func (c *Client) credentials(action string) (map[string]interface{}, error) {
+	if c.session == nil || action == userAuthenticationTokenPath {
+		return map[string]interface{}{
+			"SessionId": action,
+			"Username":  c.Username,
+			"Password":  c.Password,
+		}, nil
+	}
+
+	token, err := c.session.currentToken(c)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"SessionId": token,
+		"Username":  c.Username,
+	}, nil
+}
+
+// currentToken returns the token of the session and renews it if it is about to expire.
+func (s *session) currentToken(c *Client) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != "" && time.Now().Add(sessionRenewalMargin).Before(s.expires) {
+		return s.token, nil
+	}
+
+	req, err := c.NewRequest(userAuthenticationTokenPath, nil)
+	if err != nil {
+		return "", err
+	}
+
+	root := new(authenticationTokenRoot)
+	_, err = c.Do(req, root)
+	if err != nil {
+		return "", err
+	}
+	if root.AuthenticationToken == nil || root.AuthenticationToken.AuthenticationToken == "" {
+		return "", fmt.Errorf("No authentication token returned for user %s", c.Username)
+	}
+
+	expires := time.Now().Add(defaultSessionLifetime)
+	if root.AuthenticationToken.ExpirationDate != "" {
+		if expires, err = parseAPIDate(root.AuthenticationToken.ExpirationDate); err != nil {
+			return "", err
+		}
+	}
+
+	log.Printf("[DEBUG] New session for user %s, expires %s\n", c.Username, expires)
+	s.token = root.AuthenticationToken.AuthenticationToken
+	s.expires = expires
+	return s.token, nil
+}
+
+// invalidate forgets the token if the API rejected it, so that the next call gets a new one.
+func (s *session) invalidate(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token == token {
+		s.token = ""
+	}
+}
+
+// isAuthenticationError reports whether the API rejected the credentials of a call.
+func isAuthenticationError(err error) bool {
+	errorResponse, ok := err.(*ErrorResponse)
+	if !ok {
+		return false
+	}
+	if errorResponse.Response != nil && (errorResponse.Response.StatusCode == http.StatusUnauthorized ||
+		errorResponse.Response.StatusCode == http.StatusForbidden) {
+		return true
+	}
+
+	message := strings.ToLower(errorResponse.Message)
+	return strings.Contains(message, "authenticat") || strings.Contains(message, "session") ||
+		strings.Contains(message, "token")
+}
+
+// withNewSession returns a copy of a call rejected because of its token, with a new token
+// and RequestId. The token is renewed even if it didn't expire yet, as the API may revoke it.
+func (c *Client) withNewSession(req *http.Request) (*http.Request, error) {
+	body, err := requestBody(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]interface{}
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, err
+	}
+
+	rejected, _ := fields["SessionId"].(string)
+	c.session.invalidate(rejected)
+	log.Printf("[DEBUG] Session of user %s rejected, renewing it\n", c.Username)
+
+	token, err := c.session.currentToken(c)
+	if err != nil {
+		return nil, err
+	}
+	requestId, err := newRequestId()
+	if err != nil {
+		return nil, err
+	}
+	fields["SessionId"] = token
+	fields["RequestId"] = requestId
+
+	body, err = json.Marshal(fields)
+	if err != nil {
+		return nil, err
+	}
+
+	retry, err := http.NewRequest(req.Method, req.URL.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	retry.Header = req.Header.Clone()
+
+	ctx := context.WithValue(withRequestId(req.Context(), requestId), retryKey{}, true)
+	return retry.WithContext(ctx), nil
+}
+
+type retryKey struct{}
+
+func isRetry(ctx context.Context) bool {
+	retry, _ := ctx.Value(retryKey{}).(bool)
+	return retry
+}
+
+type requestIdKey struct{}
+
+// newRequestId returns a random UUID identifying a single API call.
+func newRequestId() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	id[6] = id[6]&0x0f | 0x40
+	id[8] = id[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", id[0:4], id[4:6], id[6:8], id[8:10], id[10:]), nil
+}
+
+func withRequestId(ctx context.Context, requestId string) context.Context {
+	return context.WithValue(ctx, requestIdKey{}, requestId)
+}
+
+func requestIdOf(ctx context.Context) string {
+	requestId, _ := ctx.Value(requestIdKey{}).(string)
+	return requestId
+}
@@ -0,0 +1,194 @@
+package goarubacloud
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+)
+
+const smartPackagesPath = "GetPreConfiguredPackages"
+
+// SmartPackagesService is an interface for the catalog of Cloud Server SMART packages
+type SmartPackagesService interface {
+	List() ([]SmartPackage, *Response, error)
+	Get(size CloudServerSmartSize) (*SmartPackage, error)
+	Smallest(cpuQuantity int, ramQuantity int) (*SmartPackage, error)
+}
+
+// SmartPackagesServiceOp handles communication with the pre-configured package related
+// methods of the Arubacloud API.
+type SmartPackagesServiceOp struct {
+	client *Client
+}
+
+var _ SmartPackagesService = &SmartPackagesServiceOp{}
+
+// SmartPackage describes the resources of a Cloud Server SMART size.
+type SmartPackage struct {
+	Size        CloudServerSmartSize
+	Name        string
+	CPUQuantity int
+
+	// RAM and disk size in GB
+	RAMQuantity int
+	DiskSize    int
+
+	// Monthly traffic in TB
+	TrafficTB int
+}
+
+// DefaultSmartPackages is the catalog used when the API doesn't provide it.
+var DefaultSmartPackages = []SmartPackage{
+	{Size: SMALL, Name: "Small", CPUQuantity: 1, RAMQuantity: 1, DiskSize: 20, TrafficTB: 2},
+	{Size: MEDIUM, Name: "Medium", CPUQuantity: 1, RAMQuantity: 2, DiskSize: 40, TrafficTB: 5},
+	{Size: LARGE, Name: "Large", CPUQuantity: 2, RAMQuantity: 4, DiskSize: 80, TrafficTB: 8},
+	{Size: EXTRALARGE, Name: "Extra Large", CPUQuantity: 4, RAMQuantity: 8, DiskSize: 160, TrafficTB: 10},
+}
+
+// Language of the package descriptions, 2 is English
+const packageDescriptionLanguage = 2
+
+type preConfiguredPackage struct {
+	PackageID    int
+	Descriptions []struct {
+		LanguageID int
+		Text       string
+	}
+	Resources []struct {
+		ResourceType ResourceType
+		Quantity     int
+	}
+	TrafficTB int
+}
+
+type preConfiguredPackagesRoot struct {
+	Packages []preConfiguredPackage `json:"Value"`
+}
+
+// List returns the catalog of Cloud Server SMART packages. The DefaultSmartPackages are
+// returned if the API doesn't support the action or returns no packages. Other errors are
+// returned.
+func (s *SmartPackagesServiceOp) List() ([]SmartPackage, *Response, error) {
+	body := struct {
+		HypervisorType HypervisorType
+	}{VMWare_Cloud_Smart}
+
+	req, err := s.client.NewRequest(smartPackagesPath, body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	root := new(preConfiguredPackagesRoot)
+	resp, err := s.client.Do(req, root)
+	if err != nil {
+		if !isUnsupportedAction(resp) {
+			return nil, resp, err
+		}
+		log.Printf("[WARN] Smart packages not supported by the API, using defaults: %s\n", err)
+		return defaultSmartPackages(), resp, nil
+	}
+
+	packages := smartPackagesFrom(root.Packages)
+	if len(packages) == 0 {
+		log.Println("[WARN] No smart packages returned, using defaults")
+		return defaultSmartPackages(), resp, nil
+	}
+
+	return packages, resp, nil
+}
+
+// isUnsupportedAction reports whether a call failed because the API doesn't know its action.
+func isUnsupportedAction(resp *Response) bool {
+	if resp == nil || resp.Response == nil {
+		return false
+	}
+	return resp.Response.StatusCode == http.StatusNotFound || resp.Response.StatusCode == http.StatusMethodNotAllowed
+}
+
+func defaultSmartPackages() []SmartPackage {
+	return append([]SmartPackage{}, DefaultSmartPackages...)
+}
+
+// smartPackagesFrom converts the pre-configured packages of the API. Resources missing in
+// the API response are taken from DefaultSmartPackages.
+func smartPackagesFrom(preConfiguredPackages []preConfiguredPackage) []SmartPackage {
+	packages := []SmartPackage{}
+	for _, preConfigured := range preConfiguredPackages {
+		smartPackage, err := findSmartPackage(DefaultSmartPackages, CloudServerSmartSize(preConfigured.PackageID))
+		if err != nil {
+			continue
+		}
+		converted := *smartPackage
+
+		for _, description := range preConfigured.Descriptions {
+			if description.LanguageID == packageDescriptionLanguage && description.Text != "" {
+				converted.Name = description.Text
+			}
+		}
+		for _, resource := range preConfigured.Resources {
+			switch resource.ResourceType {
+			case RESOURCE_CPU:
+				converted.CPUQuantity = resource.Quantity
+			case RESOURCE_RAM:
+				converted.RAMQuantity = resource.Quantity
+			case RESOURCE_VIRTUAL_DISK:
+				converted.DiskSize = resource.Quantity
+			}
+		}
+		if preConfigured.TrafficTB != 0 {
+			converted.TrafficTB = preConfigured.TrafficTB
+		}
+
+		packages = append(packages, converted)
+	}
+	return packages
+}
+
+// Get returns the package of a Cloud Server SMART size.
+func (s *SmartPackagesServiceOp) Get(size CloudServerSmartSize) (*SmartPackage, error) {
+	packages, _, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	return findSmartPackage(packages, size)
+}
+
+// Smallest returns the smallest package providing at least the given CPU and RAM.
+func (s *SmartPackagesServiceOp) Smallest(cpuQuantity int, ramQuantity int) (*SmartPackage, error) {
+	packages, _, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	return SmallestSmartPackage(packages, cpuQuantity, ramQuantity)
+}
+
+// SmallestSmartPackage returns the smallest of the packages providing at least the given CPU
+// and RAM (in GB).
+func SmallestSmartPackage(packages []SmartPackage, cpuQuantity int, ramQuantity int) (*SmartPackage, error) {
+	var smallest *SmartPackage
+	for i := range packages {
+		candidate := &packages[i]
+		if candidate.CPUQuantity < cpuQuantity || candidate.RAMQuantity < ramQuantity {
+			continue
+		}
+		if smallest == nil || candidate.Size < smallest.Size {
+			smallest = candidate
+		}
+	}
+
+	if smallest == nil {
+		return nil, fmt.Errorf("No smart package provides %d CPUs and %d GB RAM", cpuQuantity, ramQuantity)
+	}
+	found := *smallest
+	return &found, nil
+}
+
+func findSmartPackage(packages []SmartPackage, size CloudServerSmartSize) (*SmartPackage, error) {
+	for i := range packages {
+		if packages[i].Size == size {
+			found := packages[i]
+			return &found, nil
+		}
+	}
+	return nil, fmt.Errorf("No smart package for size %s", size)
+}
@@ -0,0 +1,368 @@
+package goarubacloud
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ServerSpec describes a Cloud Server in a YAML or JSON file, e.g.
+//
+//	name: web-1
+//	type: pro
+//	template: CentOS 7.x 64bit
+//	admin_password: secret
+//	cpu: 2
+//	ram: 4
+//	disks: [20, 50]
+//	public_ips: [93.184.216.34]
+//	vlans:
+//	  - name: backend
+//	    ip: 10.0.0.10
+//	    gateway: 10.0.0.1
+//	    mask: 255.255.255.0
+//	tags:
+//	  owner: alice
+//
+// Templates are found by name in the hypervisor of the spec, or by id. Public IPs and VLANs
+// must already be purchased and are referenced by address and by name.
+type ServerSpec struct {
+	Name string `json:"name" yaml:"name"`
+
+	// "pro" (default) or "smart"
+	Type string `json:"type" yaml:"type"`
+
+	// Size of a smart server: small, medium, large or extralarge
+	Size string `json:"size" yaml:"size"`
+
+	// Hypervisor of a pro server: vmware (default), hyperv or hyperv-lowcost
+	Hypervisor string `json:"hypervisor" yaml:"hypervisor"`
+
+	Template              string            `json:"template" yaml:"template"`
+	TemplateId            int               `json:"template_id" yaml:"template_id"`
+	AdministratorPassword string            `json:"admin_password" yaml:"admin_password"`
+	CPU                   int               `json:"cpu" yaml:"cpu"`
+	RAM                   int               `json:"ram" yaml:"ram"`
+	Disks                 []int             `json:"disks" yaml:"disks"`
+	PublicIPs             []string          `json:"public_ips" yaml:"public_ips"`
+	VLANs                 []VLANSpec        `json:"vlans" yaml:"vlans"`
+	IPv6                  bool              `json:"ipv6" yaml:"ipv6"`
+	Note                  string            `json:"note" yaml:"note"`
+	Tags                  map[string]string `json:"tags" yaml:"tags"`
+
+	// Lines of the fields in the spec file by field path, e.g. "disks[1]"
+	lines map[string]int
+}
+
+// VLANSpec connects a pro server to a purchased VLAN.
+type VLANSpec struct {
+	Name    string `json:"name" yaml:"name"`
+	IP      string `json:"ip" yaml:"ip"`
+	Gateway string `json:"gateway" yaml:"gateway"`
+	Mask    string `json:"mask" yaml:"mask"`
+}
+
+// SpecError is an invalid field of a ServerSpec. Line is 0 if the spec wasn't loaded with
+// LoadServerSpec.
+type SpecError struct {
+	Line  int
+	Field string
+	Err   error
+}
+
+var _ error = &SpecError{}
+
+func (e *SpecError) Error() string {
+	message := e.Err.Error()
+	if e.Field != "" {
+		message = fmt.Sprintf("%s: %s", e.Field, message)
+	}
+	if e.Line != 0 {
+		message = fmt.Sprintf("line %d: %s", e.Line, message)
+	}
+	return message
+}
+
+func (e *SpecError) Unwrap() error {
+	return e.Err
+}
+
+var specHypervisorTypes = map[string]HypervisorType{
+	"vmware":         VMWare_Cloud_Pro,
+	"hyperv":         Microsoft_Hyper_V,
+	"hyperv-lowcost": Microsoft_Hyper_V_Low_Cost,
+}
+
+// LoadServerSpec reads a ServerSpec from YAML or JSON. Unknown fields are rejected.
+func LoadServerSpec(r io.Reader) (*ServerSpec, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	spec := new(ServerSpec)
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(spec); err != nil {
+		return nil, &SpecError{Err: err}
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, &SpecError{Err: err}
+	}
+	spec.lines = map[string]int{}
+	recordLines(&root, "", spec.lines)
+
+	return spec, nil
+}
+
+func recordLines(node *yaml.Node, path string, lines map[string]int) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		for _, child := range node.Content {
+			recordLines(child, path, lines)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			if path != "" {
+				key = path + "." + key
+			}
+			lines[key] = node.Content[i].Line
+			recordLines(node.Content[i+1], key, lines)
+		}
+	case yaml.SequenceNode:
+		for i, child := range node.Content {
+			key := fmt.Sprintf("%s[%d]", path, i)
+			lines[key] = child.Line
+			recordLines(child, key, lines)
+		}
+	}
+}
+
+func (s *ServerSpec) fieldError(field string, err error) error {
+	return &SpecError{Line: s.lines[field], Field: field, Err: err}
+}
+
+func (s *ServerSpec) errorf(field string, format string, a ...interface{}) error {
+	return s.fieldError(field, fmt.Errorf(format, a...))
+}
+
+// Compile builds a validated create request from the spec. Templates, public IPs and VLANs
+// are looked up with the client.
+func (s *ServerSpec) Compile(client *Client) (CloudServerCreator, error) {
+	if s.Name == "" {
+		return nil, s.errorf("name", "is required")
+	}
+	if s.AdministratorPassword == "" {
+		return nil, s.errorf("admin_password", "is required")
+	}
+	if s.Template != "" && s.TemplateId != 0 {
+		return nil, s.errorf("template", "only one of template and template_id may be set")
+	}
+	if s.Template == "" && s.TemplateId == 0 {
+		return nil, s.errorf("template", "template or template_id is required")
+	}
+
+	var creator CloudServerCreator
+	var err error
+	switch strings.ToLower(s.Type) {
+	case "", "pro":
+		creator, err = s.compilePro(client)
+	case "smart":
+		creator, err = s.compileSmart(client)
+	default:
+		return nil, s.errorf("type", "'%s' is not one of 'pro', 'smart'", s.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for key, value := range s.Tags {
+		if err := validateTag(key, value); err != nil {
+			return nil, s.fieldError("tags."+key, err)
+		}
+	}
+	note, err := FormatNoteTags(s.Note, s.Tags)
+	if err != nil {
+		return nil, s.fieldError("note", err)
+	}
+	if err := creator.SetNote(note); err != nil {
+		return nil, s.fieldError("note", err)
+	}
+
+	if s.IPv6 {
+		if err := creator.EnableIPv6(); err != nil {
+			return nil, s.fieldError("ipv6", err)
+		}
+	}
+
+	return creator, nil
+}
+
+func (s *ServerSpec) compileSmart(client *Client) (CloudServerCreator, error) {
+	proFields := []struct {
+		name string
+		set  bool
+	}{
+		{"hypervisor", s.Hypervisor != ""},
+		{"cpu", s.CPU != 0},
+		{"ram", s.RAM != 0},
+		{"disks", len(s.Disks) > 0},
+		{"public_ips", len(s.PublicIPs) > 0},
+		{"vlans", len(s.VLANs) > 0},
+	}
+	for _, field := range proFields {
+		if field.set {
+			return nil, s.errorf(field.name, "is only supported by pro servers")
+		}
+	}
+
+	if s.Size == "" {
+		return nil, s.errorf("size", "is required for smart servers")
+	}
+	size, err := ParseCloudServerSmartSize(s.Size)
+	if err != nil {
+		return nil, s.fieldError("size", err)
+	}
+
+	template, err := s.findTemplate(client, VMWare_Cloud_Smart)
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidateAdministratorPassword(s.AdministratorPassword, template); err != nil {
+		return nil, s.fieldError("admin_password", err)
+	}
+
+	creator := NewCloudServerSmartCreateRequest(size, s.Name, s.AdministratorPassword, template.Id)
+	if _, err := client.Hypervisors.ResolveTemplate(creator); err != nil {
+		return nil, s.fieldError(s.templateField(), err)
+	}
+	return creator, nil
+}
+
+func (s *ServerSpec) compilePro(client *Client) (CloudServerCreator, error) {
+	if s.Size != "" {
+		return nil, s.errorf("size", "is only supported by smart servers")
+	}
+
+	hypervisorType := VMWare_Cloud_Pro
+	if s.Hypervisor != "" {
+		var ok bool
+		hypervisorType, ok = specHypervisorTypes[strings.ToLower(s.Hypervisor)]
+		if !ok {
+			return nil, s.errorf("hypervisor", "'%s' is not one of 'vmware', 'hyperv', 'hyperv-lowcost'", s.Hypervisor)
+		}
+	}
+
+	template, err := s.findTemplate(client, hypervisorType)
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidateAdministratorPassword(s.AdministratorPassword, template); err != nil {
+		return nil, s.fieldError("admin_password", err)
+	}
+
+	creator, err := NewCloudServerProCreateRequestForTemplate(s.Name, s.AdministratorPassword, template)
+	if err != nil {
+		return nil, s.fieldError(s.templateField(), err)
+	}
+	if _, err := client.Hypervisors.ResolveTemplate(creator); err != nil {
+		return nil, s.fieldError(s.templateField(), err)
+	}
+
+	if s.CPU != 0 {
+		if err := creator.SetCPUQuantity(s.CPU); err != nil {
+			return nil, s.fieldError("cpu", err)
+		}
+	}
+	if s.RAM != 0 {
+		if err := creator.SetRAMQuantity(s.RAM); err != nil {
+			return nil, s.fieldError("ram", err)
+		}
+	}
+	for i, size := range s.Disks {
+		if err := creator.AddVirtualDisk(size); err != nil {
+			return nil, s.fieldError(fmt.Sprintf("disks[%d]", i), err)
+		}
+	}
+
+	if len(s.PublicIPs) > 0 {
+		purchasedIPs, _, err := client.PurchasedIPs.List()
+		if err != nil {
+			return nil, err
+		}
+		for i, address := range s.PublicIPs {
+			field := fmt.Sprintf("public_ips[%d]", i)
+			resourceId := 0
+			for _, ip := range purchasedIPs {
+				if ip.Value == address {
+					resourceId = ip.ResourceId
+				}
+			}
+			if resourceId == 0 {
+				return nil, s.errorf(field, "%s is not a purchased IP", address)
+			}
+			if err := creator.AddPublicIp(resourceId); err != nil {
+				return nil, s.fieldError(field, err)
+			}
+		}
+	}
+
+	if len(s.VLANs) > 0 {
+		vlans, _, err := client.VLANs.List()
+		if err != nil {
+			return nil, err
+		}
+		for i, vlanSpec := range s.VLANs {
+			field := fmt.Sprintf("vlans[%d]", i)
+			resourceId := 0
+			for _, vlan := range vlans {
+				if vlan.Name == vlanSpec.Name {
+					resourceId = vlan.ResourceId
+				}
+			}
+			if resourceId == 0 {
+				return nil, s.errorf(field+".name", "'%s' is not a purchased VLAN", vlanSpec.Name)
+			}
+			if err := creator.AddVLAN(resourceId, vlanSpec.IP, vlanSpec.Gateway, vlanSpec.Mask); err != nil {
+				return nil, s.fieldError(field, err)
+			}
+		}
+	}
+
+	return creator, nil
+}
+
+func (s *ServerSpec) templateField() string {
+	if s.TemplateId != 0 {
+		return "template_id"
+	}
+	return "template"
+}
+
+// findTemplate looks up the template by id, or by name in the hypervisor.
+func (s *ServerSpec) findTemplate(client *Client, hypervisorType HypervisorType) (*OSTemplate, error) {
+	if s.TemplateId == 0 {
+		template, err := client.Hypervisors.FindOsTemplate(hypervisorType, s.Template)
+		if err != nil {
+			return nil, s.fieldError("template", err)
+		}
+		return template, nil
+	}
+
+	hypervisors, _, err := client.Hypervisors.GetHypervisors()
+	if err != nil {
+		return nil, err
+	}
+	template, _ := findOsTemplateById(hypervisors, s.TemplateId)
+	if template == nil {
+		return nil, s.errorf("template_id", "template %d does not exist", s.TemplateId)
+	}
+	return template, nil
+}
@@ -0,0 +1,207 @@
+package goarubacloud
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Tags are stored at the end of the server note in a block like:
+//
+//	--- tags ---
+//	owner: alice
+//	environment: production
+//	--- end tags ---
+//
+// Only these exact marker lines are matched, case insensitive. Both "key: value" and
+// "key=value" are accepted. Text outside the block is preserved.
+const (
+	tagsBeginMarker = "--- tags ---"
+	tagsEndMarker   = "--- end tags ---"
+)
+
+// TagsService is an interface for managing key/value tags stored in the note of a
+// Cloud Server
+type TagsService interface {
+	GetTags(serverId int) (map[string]string, *Response, error)
+	SetTags(serverId int, tags map[string]string) (*Response, error)
+	RemoveTag(serverId int, key string) (*Response, error)
+	List(key string, value string) ([]CloudServerDetails, *Response, error)
+}
+
+// TagsServiceOp handles the tags stored in the notes of Cloud Servers.
+type TagsServiceOp struct {
+	client *Client
+}
+
+var _ TagsService = &TagsServiceOp{}
+
+// ParseNoteTags splits a server note into its free text and its tags. Lines of the block
+// which are not tags are kept in the free text, and a block without its end marker is free
+// text as well.
+func ParseNoteTags(note string) (string, map[string]string) {
+	tags := map[string]string{}
+	var text []string
+
+	lines := strings.Split(note, "\n")
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], "\r")
+		if isTagsMarker(line, tagsBeginMarker) {
+			if end := tagsBlockEnd(lines, i+1); end != -1 {
+				for _, blockLine := range lines[i+1 : end] {
+					blockLine = strings.TrimRight(blockLine, "\r")
+					if key, value, ok := parseTagLine(blockLine); ok {
+						tags[key] = value
+					} else if strings.TrimSpace(blockLine) != "" {
+						text = append(text, blockLine)
+					}
+				}
+				i = end
+				continue
+			}
+		}
+		text = append(text, line)
+	}
+
+	return strings.TrimRight(strings.Join(text, "\n"), "\n\t "), tags
+}
+
+func isTagsMarker(line string, marker string) bool {
+	return strings.EqualFold(strings.TrimSpace(line), marker)
+}
+
+// tagsBlockEnd returns the index of the end marker following a begin marker, or -1.
+func tagsBlockEnd(lines []string, start int) int {
+	for i := start; i < len(lines); i++ {
+		if isTagsMarker(lines[i], tagsEndMarker) {
+			return i
+		}
+		if isTagsMarker(lines[i], tagsBeginMarker) {
+			return -1
+		}
+	}
+	return -1
+}
+
+func parseTagLine(line string) (string, string, bool) {
+	separator := strings.IndexAny(line, ":=")
+	if separator == -1 {
+		return "", "", false
+	}
+
+	key := strings.TrimSpace(line[:separator])
+	if key == "" {
+		return "", "", false
+	}
+	return key, strings.TrimSpace(line[separator+1:]), true
+}
+
+// FormatNoteTags builds a server note from free text and tags. Tags are written sorted by key.
+func FormatNoteTags(text string, tags map[string]string) (string, error) {
+	if len(tags) == 0 {
+		return text, validateNote(text)
+	}
+
+	keys := make([]string, 0, len(tags))
+	for key, value := range tags {
+		if err := validateTag(key, value); err != nil {
+			return "", err
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	lines := []string{tagsBeginMarker}
+	for _, key := range keys {
+		lines = append(lines, fmt.Sprintf("%s: %s", key, tags[key]))
+	}
+	lines = append(lines, tagsEndMarker)
+
+	block := strings.Join(lines, "\n")
+	note := block
+	if text != "" {
+		note = text + "\n\n" + block
+	}
+
+	return note, validateNote(note)
+}
+
+func validateTag(key string, value string) error {
+	if strings.TrimSpace(key) != key || key == "" {
+		return NewArgError("key", fmt.Sprintf("'%s' must not be empty or start or end with spaces", key))
+	}
+	if strings.ContainsAny(key, ":=\r\n") {
+		return NewArgError("key", fmt.Sprintf("'%s' must not contain ':', '=' or line breaks", key))
+	}
+	if strings.ContainsAny(value, "\r\n") {
+		return NewArgError("value", fmt.Sprintf("value of '%s' must not contain line breaks", key))
+	}
+	return nil
+}
+
+// GetTags returns the tags of a Cloud Server.
+func (s *TagsServiceOp) GetTags(serverId int) (map[string]string, *Response, error) {
+	serverDetails, resp, err := s.client.CloudServers.Get(serverId)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	_, tags := ParseNoteTags(serverDetails.Note)
+	return tags, resp, nil
+}
+
+// SetTags adds the tags to a Cloud Server, replacing the values of existing keys.
+func (s *TagsServiceOp) SetTags(serverId int, tags map[string]string) (*Response, error) {
+	return s.updateTags(serverId, func(current map[string]string) {
+		for key, value := range tags {
+			current[key] = value
+		}
+	})
+}
+
+// RemoveTag removes a tag from a Cloud Server.
+func (s *TagsServiceOp) RemoveTag(serverId int, key string) (*Response, error) {
+	return s.updateTags(serverId, func(current map[string]string) {
+		delete(current, key)
+	})
+}
+
+// List returns the Cloud Servers having the tag. An empty value matches any value.
+func (s *TagsServiceOp) List(key string, value string) ([]CloudServerDetails, *Response, error) {
+	servers, resp, err := s.client.CloudServers.List()
+	if err != nil {
+		return nil, resp, err
+	}
+
+	var tagged []CloudServerDetails
+	for _, server := range servers {
+		serverDetails, resp, err := s.client.CloudServers.Get(server.ServerId)
+		if err != nil {
+			return nil, resp, err
+		}
+
+		_, tags := ParseNoteTags(serverDetails.Note)
+		if tagValue, ok := tags[key]; ok && (value == "" || tagValue == value) {
+			tagged = append(tagged, *serverDetails)
+		}
+	}
+
+	return tagged, resp, nil
+}
+
+func (s *TagsServiceOp) updateTags(serverId int, update func(map[string]string)) (*Response, error) {
+	serverDetails, resp, err := s.client.CloudServers.Get(serverId)
+	if err != nil {
+		return resp, err
+	}
+
+	text, tags := ParseNoteTags(serverDetails.Note)
+	update(tags)
+
+	note, err := FormatNoteTags(text, tags)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.client.CloudServers.Update(serverId, serverDetails.Name, note)
+}
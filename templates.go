@@ -0,0 +1,165 @@
+package goarubacloud
+
+import (
+	"fmt"
+	"time"
+)
+
+const templateCreatePath = "SetEnqueueTemplateCreation"
+const templateDeletePath = "SetEnqueueTemplateDeletion"
+
+// TemplateOwnershipType of templates owned by the user
+const privateTemplateOwnershipType = 2
+
+// TemplatesService is an interface for managing the private templates of the user
+type TemplatesService interface {
+	Create(serverId int, name string, description string) (*OSTemplate, *Response, error)
+	List() ([]OSTemplate, *Response, error)
+	Delete(templateId int) (*Response, error)
+}
+
+// TemplatesServiceOp handles communication with the template related methods of the
+// Arubacloud API.
+type TemplatesServiceOp struct {
+	client *Client
+}
+
+var _ TemplatesService = &TemplatesServiceOp{}
+
+type templateCreateRequest struct {
+	ServerId    int
+	Name        string
+	Description string
+}
+
+// IsPrivate reports whether the template is owned by the user.
+func (t *OSTemplate) IsPrivate() bool {
+	return t.TemplateOwnershipType == privateTemplateOwnershipType
+}
+
+// Create a private template from a powered off Cloud Server and wait until it is available.
+// The name must not be used by another private template.
+func (s *TemplatesServiceOp) Create(serverId int, name string, description string) (*OSTemplate, *Response, error) {
+	if name == "" {
+		return nil, nil, NewArgError("name", "cannot be empty")
+	}
+
+	serverDetails, resp, err := s.client.CloudServers.Get(serverId)
+	if err != nil {
+		return nil, resp, err
+	}
+	if serverDetails.ServerStatus != OFF {
+		return nil, nil, NewArgError("serverId", fmt.Sprintf("server %d must be powered off", serverId))
+	}
+
+	templates, resp, err := s.List()
+	if err != nil {
+		return nil, resp, err
+	}
+	for _, template := range templates {
+		if template.Name == name {
+			return nil, nil, NewArgError("name", fmt.Sprintf("private template '%s' already exists", name))
+		}
+	}
+
+	data := struct {
+		Template interface{} `json:"Template"`
+	}{templateCreateRequest{ServerId: serverId, Name: name, Description: description}}
+
+	req, err := s.client.NewRequest(templateCreatePath, data)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resp, err = s.client.Do(req, nil)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	template, err := WaitForTemplateWithName(s.client, name)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	return template, resp, nil
+}
+
+// List the private templates of the user
+func (s *TemplatesServiceOp) List() ([]OSTemplate, *Response, error) {
+	hypervisors, resp, err := s.client.Hypervisors.GetHypervisors()
+	if err != nil {
+		return nil, resp, err
+	}
+
+	templates := []OSTemplate{}
+	for _, hypervisor := range hypervisors {
+		for _, template := range hypervisor.Templates {
+			if template.IsPrivate() {
+				templates = append(templates, template)
+			}
+		}
+	}
+
+	return templates, resp, nil
+}
+
+// Delete a private template
+func (s *TemplatesServiceOp) Delete(templateId int) (*Response, error) {
+	templates, resp, err := s.List()
+	if err != nil {
+		return resp, err
+	}
+
+	found := false
+	for _, template := range templates {
+		if template.Id == templateId {
+			found = true
+		}
+	}
+	if !found {
+		return nil, NewArgError("templateId", fmt.Sprintf("%d is not a private template", templateId))
+	}
+
+	body := struct{ TemplateId int }{TemplateId: templateId}
+	req, err := s.client.NewRequest(templateDeletePath, body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err = s.client.Do(req, nil)
+	if err != nil {
+		return resp, err
+	}
+
+	return resp, nil
+}
+
+// WaitForTemplateWithName waits for an enabled private template with specified name
+func WaitForTemplateWithName(client *Client, name string) (*OSTemplate, error) {
+	if client.DryRun {
+		return &OSTemplate{Name: name}, nil
+	}
+	failCount := 0
+	for {
+		client.forgetCached(hypervisorsPath)
+		templates, _, err := client.Templates.List()
+
+		if err != nil {
+			if failCount <= maxRetries {
+				failCount++
+				continue
+			}
+			return nil, err
+		}
+
+		for _, template := range templates {
+			if template.Name == name && template.Enabled {
+				return &template, nil
+			}
+		}
+
+		time.Sleep(15 * time.Second)
+	}
+}
@@ -101,6 +101,9 @@ func (s *VLANsServiceOp) Purchase(name string) (*PurchasedVLAN, *Response, error
 	if err != nil {
 		return nil, resp, err
 	}
+	if resp.DryRun != nil {
+		return &PurchasedVLAN{Name: name, ResourceId: DryRunResourceId}, resp, nil
+	}
 
 	return root.PurchasedVLAN, resp, nil
 }